@@ -0,0 +1,199 @@
+// Package adaptor implements ECDSA adaptor signatures on secp256k1, also known
+// as one-time verifiably encrypted signatures.
+//
+// An encrypted signature is created with an encryption key Y = y*G, and can be
+// verified against Y by anyone. Only the holder of y can decrypt it into a
+// valid ECDSA signature, and anyone who sees both the encrypted and the
+// decrypted signature can recover y. If Y is CommitmentA of a DLEQ proof, y is
+// also the secret of CommitmentB on the other curve, eg. a Monero key.
+//
+// It also implements Schnorr adaptor signatures for BIP340 and Ed25519, where
+// a pre-signature is adapted with the discrete logarithm of its adaptor point.
+package adaptor
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/athanorlabs/go-dleq/chaumpedersen"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+var (
+	errInvalidHashLength = errors.New("message hash must be 32 bytes")
+	errInvalidSignature  = errors.New("invalid encrypted signature")
+)
+
+var curve = secp256k1.NewCurve()
+
+// EncryptedSignature is an ECDSA signature encrypted with an encryption key Y.
+//
+// It consists of R' = k*G, R = k*Y and s' = k^-1 * (m + r*x), where r is the
+// x coordinate of R and x is the signing key, along with a proof that R' and
+// R have the same discrete logarithm with respect to G and Y.
+type EncryptedSignature struct {
+	sHat types.Scalar
+	// proof.X is R' and proof.Y is R.
+	proof *chaumpedersen.Proof
+}
+
+// EncSign returns the signature of the 32-byte message hash by the signing
+// key sk, encrypted with encKey.
+func EncSign(sk types.Scalar, encKey types.Point, hash []byte) (*EncryptedSignature, error) {
+	if len(hash) != 32 {
+		return nil, errInvalidHashLength
+	}
+
+	if sk.IsZero() || encKey.IsZero() {
+		return nil, errors.New("keys must not be zero")
+	}
+
+	m := hashToScalar(hash)
+	for {
+		k := curve.NewRandomScalar()
+		if k.IsZero() {
+			continue
+		}
+
+		proof, err := chaumpedersen.NewProof(curve, curve.BasePoint(), encKey, k)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := xCoordinate(proof.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		// s' = k^-1 * (m + r*x)
+		sHat := k.Inverse().Mul(m.Add(r.Mul(sk)))
+		if r.IsZero() || sHat.IsZero() {
+			continue
+		}
+
+		return &EncryptedSignature{
+			sHat:  sHat,
+			proof: proof,
+		}, nil
+	}
+}
+
+// EncVerify verifies that sig is a signature of the 32-byte message hash by
+// the public key pk, encrypted with encKey.
+func EncVerify(pk, encKey types.Point, hash []byte, sig *EncryptedSignature) error {
+	if len(hash) != 32 {
+		return errInvalidHashLength
+	}
+
+	if sig.sHat == nil || sig.proof == nil {
+		return errInvalidSignature
+	}
+
+	err := sig.proof.Verify(curve, curve.BasePoint(), encKey)
+	if err != nil {
+		return err
+	}
+
+	r, err := xCoordinate(sig.proof.Y)
+	if err != nil {
+		return err
+	}
+
+	if r.IsZero() || sig.sHat.IsZero() {
+		return errInvalidSignature
+	}
+
+	// s'*R' = m*G + r*X
+	m := hashToScalar(hash)
+	lhs := sig.proof.X.ScalarMul(sig.sHat)
+	rhs := curve.ScalarBaseMul(m).Add(pk.ScalarMul(r))
+	if !lhs.Equals(rhs) {
+		return errInvalidSignature
+	}
+
+	return nil
+}
+
+// Decrypt decrypts sig with the decryption key y of its encryption key,
+// returning an ECDSA signature. The signature is only valid if sig was
+// verified with EncVerify and y is the correct key.
+func Decrypt(sig *EncryptedSignature, y types.Scalar) (*Signature, error) {
+	if sig.sHat == nil || sig.proof == nil {
+		return nil, errInvalidSignature
+	}
+
+	if y.IsZero() {
+		return nil, errors.New("decryption key must not be zero")
+	}
+
+	r, err := xCoordinate(sig.proof.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	// s = s' * y^-1
+	s := sig.sHat.Mul(y.Inverse())
+	if isHigh(s) {
+		s = s.Negate()
+	}
+
+	return &Signature{
+		R: r,
+		S: s,
+	}, nil
+}
+
+// Recover returns the decryption key of encKey, given an encrypted signature
+// and its decrypted signature.
+//
+// The decryption key is a secp256k1 scalar; its little-endian encoding is the
+// witness of a DLEQ proof with CommitmentA = encKey.
+func Recover(encKey types.Point, encSig *EncryptedSignature, sig *Signature) (types.Scalar, error) {
+	if encSig.sHat == nil || sig.S == nil || sig.S.IsZero() {
+		return nil, errInvalidSignature
+	}
+
+	// y = s' * s^-1, up to the sign of s
+	y := encSig.sHat.Mul(sig.S.Inverse())
+	if curve.ScalarBaseMul(y).Equals(encKey) {
+		return y, nil
+	}
+
+	y = y.Negate()
+	if curve.ScalarBaseMul(y).Equals(encKey) {
+		return y, nil
+	}
+
+	return nil, errors.New("signature was not decrypted from the encrypted signature")
+}
+
+// hashToScalar converts a 32-byte big-endian message hash to a scalar, as for ECDSA.
+func hashToScalar(hash []byte) types.Scalar {
+	return curve.ScalarFromBytes(reverse(hash))
+}
+
+// xCoordinate returns the x coordinate of p reduced modulo the curve order.
+func xCoordinate(p types.Point) (types.Scalar, error) {
+	x, err := secp256k1.XOnlyPublicKey(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return curve.ScalarFromBytes(reverse(x)), nil
+}
+
+var halfOrder = new(big.Int).Rsh(curve.(types.Orderer).Order(), 1)
+
+// isHigh returns whether s is greater than half the curve order.
+func isHigh(s types.Scalar) bool {
+	return new(big.Int).SetBytes(s.Encode()).Cmp(halfOrder) > 0
+}
+
+func reverse(in []byte) []byte {
+	out := make([]byte, len(in))
+	for i := range in {
+		out[i] = in[len(in)-1-i]
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package adaptor
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dcrsecp256k1 "github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+
+	dleq "github.com/athanorlabs/go-dleq"
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// verifyWithDecred verifies the signature with an independent ECDSA implementation.
+func verifyWithDecred(t *testing.T, sig *Signature, pk types.Point, hash []byte) bool {
+	var r, s dcrsecp256k1.ModNScalar
+	require.False(t, r.SetByteSlice(sig.R.Encode()))
+	require.False(t, s.SetByteSlice(sig.S.Encode()))
+	pub, err := dcrsecp256k1.ParsePubKey(pk.Encode())
+	require.NoError(t, err)
+	return ecdsa.NewSignature(&r, &s).Verify(hash, pub)
+}
+
+func TestEncSignAndDecrypt(t *testing.T) {
+	sk := curve.NewRandomScalar()
+	pk := curve.ScalarBaseMul(sk)
+	y := curve.NewRandomScalar()
+	Y := curve.ScalarBaseMul(y)
+	hash := sha256.Sum256([]byte("refund transaction"))
+
+	encSig, err := EncSign(sk, Y, hash[:])
+	require.NoError(t, err)
+	err = EncVerify(pk, Y, hash[:], encSig)
+	require.NoError(t, err)
+
+	other := sha256.Sum256([]byte("claim transaction"))
+	err = EncVerify(pk, Y, other[:], encSig)
+	require.Error(t, err)
+	err = EncVerify(Y, Y, hash[:], encSig)
+	require.Error(t, err)
+	err = EncVerify(pk, pk, hash[:], encSig)
+	require.Error(t, err)
+
+	sig, err := Decrypt(encSig, y)
+	require.NoError(t, err)
+	require.False(t, isHigh(sig.S))
+	require.True(t, sig.Verify(pk, hash[:]))
+	require.True(t, verifyWithDecred(t, sig, pk, hash[:]))
+	require.False(t, sig.Verify(pk, other[:]))
+
+	// decrypting with the wrong key doesn't produce a valid signature
+	bad, err := Decrypt(encSig, sk)
+	require.NoError(t, err)
+	require.False(t, bad.Verify(pk, hash[:]))
+
+	recovered, err := Recover(Y, encSig, sig)
+	require.NoError(t, err)
+	require.True(t, recovered.Eq(y))
+
+	_, err = Recover(pk, encSig, sig)
+	require.Error(t, err)
+}
+
+func TestSerialize(t *testing.T) {
+	sk := curve.NewRandomScalar()
+	pk := curve.ScalarBaseMul(sk)
+	y := curve.NewRandomScalar()
+	Y := curve.ScalarBaseMul(y)
+	hash := sha256.Sum256([]byte("refund transaction"))
+
+	encSig, err := EncSign(sk, Y, hash[:])
+	require.NoError(t, err)
+
+	ser := encSig.Serialize()
+	deser := new(EncryptedSignature)
+	err = deser.Deserialize(ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = EncVerify(pk, Y, hash[:], deser)
+	require.NoError(t, err)
+	err = deser.Deserialize(ser[:len(ser)-1])
+	require.Error(t, err)
+
+	sig, err := Decrypt(deser, y)
+	require.NoError(t, err)
+	sigBytes := sig.Serialize()
+	require.Equal(t, SignatureSize, len(sigBytes))
+	deserSig := new(Signature)
+	err = deserSig.Deserialize(sigBytes)
+	require.NoError(t, err)
+	require.True(t, deserSig.Verify(pk, hash[:]))
+}
+
+func TestDLEQ(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+
+	// Bob proves his key on both curves
+	x, err := dleq.GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	proof, err := dleq.NewProof(curveA, curveB, x)
+	require.NoError(t, err)
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	// Alice signs Bob's claim transaction, encrypted with Bob's secp256k1 key
+	aliceSk := curveA.NewRandomScalar()
+	alicePk := curveA.ScalarBaseMul(aliceSk)
+	hash := sha256.Sum256([]byte("claim transaction"))
+	encSig, err := EncSign(aliceSk, proof.CommitmentA, hash[:])
+	require.NoError(t, err)
+	err = EncVerify(alicePk, proof.CommitmentA, hash[:], encSig)
+	require.NoError(t, err)
+
+	// Bob decrypts the signature to claim, which publishes it
+	sig, err := Decrypt(encSig, curveA.ScalarFromBytes(x))
+	require.NoError(t, err)
+	require.True(t, verifyWithDecred(t, sig, alicePk, hash[:]))
+
+	// Alice recovers Bob's key, which is also his ed25519 key
+	y, err := Recover(proof.CommitmentA, encSig, sig)
+	require.NoError(t, err)
+	witness := reverse(y.Encode())
+	require.True(t, curveB.ScalarBaseMul(curveB.ScalarFromBytes(witness)).Equals(proof.CommitmentB))
+}
@@ -0,0 +1,306 @@
+package adaptor
+
+import (
+	stded25519 "crypto/ed25519"
+	"errors"
+	"reflect"
+
+	dleq "github.com/athanorlabs/go-dleq"
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+var errInvalidPreSignature = errors.New("invalid pre-signature")
+
+// SchnorrScheme is a Schnorr signature scheme, ie. one where a signature by the
+// secret key x of P = x*G is (R, s) with s*G = R + c*P for the challenge c.
+type SchnorrScheme interface {
+	Curve() types.Curve
+	// Normalize returns the point used in place of p in signatures and whether
+	// it's -p, as for BIP340, where points have an even y coordinate.
+	Normalize(p types.Point) (types.Point, bool)
+	// Challenge returns the challenge of a signature with the normalized nonce
+	// point R by the normalized public key P.
+	Challenge(R, P types.Point, msg []byte) (types.Scalar, error)
+	EncodeSignature(R types.Point, s types.Scalar) []byte
+	// DecodeSignature returns the normalized nonce point and s of a signature.
+	DecodeSignature(sig []byte) (types.Point, types.Scalar, error)
+	Verify(P types.Point, msg, sig []byte) bool
+}
+
+var (
+	// BIP340 is the BIP340 Schnorr signature scheme on secp256k1.
+	BIP340 SchnorrScheme = bip340Scheme{secp256k1.NewCurve()}
+	// Ed25519 is the Ed25519 signature scheme of RFC 8032, with the secret
+	// key being the scalar itself rather than a seed, as for Monero keys.
+	Ed25519 SchnorrScheme = ed25519Scheme{ed25519.NewCurve()}
+)
+
+// PreSignature is a Schnorr signature which is missing the discrete logarithm
+// t of its adaptor point T. It's adapted into a valid signature with t, and
+// t can be extracted from the pre-signature and the adapted signature.
+type PreSignature struct {
+	// R = k*G + T is the nonce point of the adapted signature.
+	R types.Point
+	// s' = k + c*x, or -k + c*x if R is negated when normalized.
+	s types.Scalar
+}
+
+// PreSign returns a pre-signature of msg by the secret key sk, with the
+// adaptor point T.
+func PreSign(scheme SchnorrScheme, sk types.Scalar, T types.Point, msg []byte) (*PreSignature, error) {
+	curve := scheme.Curve()
+	if sk.IsZero() || T.IsZero() {
+		return nil, errors.New("keys must not be zero")
+	}
+
+	P, negP := scheme.Normalize(curve.ScalarBaseMul(sk))
+	if negP {
+		sk = sk.Negate()
+	}
+
+	for {
+		k := curve.NewRandomScalar()
+		R := curve.ScalarBaseMul(k).Add(T)
+		if k.IsZero() || R.IsZero() {
+			continue
+		}
+
+		normR, negR := scheme.Normalize(R)
+		c, err := scheme.Challenge(normR, P, msg)
+		if err != nil {
+			return nil, err
+		}
+
+		if negR {
+			k = k.Negate()
+		}
+
+		return &PreSignature{
+			R: R,
+			s: k.Add(c.Mul(sk)),
+		}, nil
+	}
+}
+
+// VerifyPreSignature verifies that pre is a pre-signature of msg by the public
+// key P with the adaptor point T. T should be a commitment of a verified
+// dleq.Proof on the scheme's curve, so that adapting the signature reveals
+// the proof's witness.
+func VerifyPreSignature(scheme SchnorrScheme, P, T types.Point, msg []byte, pre *PreSignature) error {
+	if pre.R == nil || pre.s == nil || pre.R.IsZero() {
+		return errInvalidPreSignature
+	}
+
+	normP, _ := scheme.Normalize(P)
+	normR, negR := scheme.Normalize(pre.R)
+	c, err := scheme.Challenge(normR, normP, msg)
+	if err != nil {
+		return err
+	}
+
+	// s'*G = k*G + c*P, where k*G = R - T, or T - R if R is negated
+	K := pre.R.Sub(T)
+	if negR {
+		K = T.Sub(pre.R)
+	}
+
+	if !scheme.Curve().ScalarBaseMul(pre.s).Equals(K.Add(normP.ScalarMul(c))) {
+		return errInvalidPreSignature
+	}
+
+	return nil
+}
+
+// VerifyPreSignatureWithProof verifies the proof for the given curves, and
+// that pre is a pre-signature of msg by the public key P with the adaptor
+// point being the proof's commitment on the curve at the given index, which
+// must be the scheme's curve.
+func VerifyPreSignatureWithProof(
+	scheme SchnorrScheme,
+	P types.Point,
+	msg []byte,
+	pre *PreSignature,
+	curves []types.Curve,
+	proof *dleq.Proof,
+	index int,
+	opts ...dleq.VerifyOption,
+) error {
+	if index < 0 || index >= len(curves) {
+		return errors.New("invalid curve index")
+	}
+
+	if reflect.TypeOf(curves[index]) != reflect.TypeOf(scheme.Curve()) {
+		return errors.New("curve at the given index is not the scheme's curve")
+	}
+
+	err := proof.VerifyForCurves(curves, opts...)
+	if err != nil {
+		return err
+	}
+
+	return VerifyPreSignature(scheme, P, proof.Commitments[index], msg, pre)
+}
+
+// Adapt adapts the pre-signature into a valid signature, given the discrete
+// logarithm t of its adaptor point.
+func Adapt(scheme SchnorrScheme, pre *PreSignature, t types.Scalar) []byte {
+	normR, negR := scheme.Normalize(pre.R)
+	if negR {
+		return scheme.EncodeSignature(normR, pre.s.Sub(t))
+	}
+
+	return scheme.EncodeSignature(normR, pre.s.Add(t))
+}
+
+// Extract returns the discrete logarithm of the adaptor point T, given the
+// pre-signature and the signature adapted from it.
+func Extract(scheme SchnorrScheme, pre *PreSignature, sig []byte, T types.Point) (types.Scalar, error) {
+	R, s, err := scheme.DecodeSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	normR, negR := scheme.Normalize(pre.R)
+	if !R.Equals(normR) {
+		return nil, errors.New("signature was not adapted from the pre-signature")
+	}
+
+	t := s.Sub(pre.s)
+	if negR {
+		t = t.Negate()
+	}
+
+	if !scheme.Curve().ScalarBaseMul(t).Equals(T) {
+		return nil, errors.New("signature was not adapted from the pre-signature")
+	}
+
+	return t, nil
+}
+
+// Serialize encodes the pre-signature as R || s'.
+func (p *PreSignature) Serialize() []byte {
+	return append(p.R.Encode(), p.s.Encode()...)
+}
+
+// Deserialize decodes a pre-signature for the given scheme.
+func (p *PreSignature) Deserialize(scheme SchnorrScheme, in []byte) error {
+	curve := scheme.Curve()
+	pointLen := curve.CompressedPointSize()
+	if len(in) != pointLen+curve.ScalarSize() {
+		return errInvalidInputLength
+	}
+
+	R, err := curve.DecodeToPoint(in[:pointLen])
+	if err != nil {
+		return err
+	}
+
+	s, err := curve.DecodeToScalar(in[pointLen:])
+	if err != nil {
+		return err
+	}
+
+	p.R, p.s = R, s
+	return nil
+}
+
+type bip340Scheme struct {
+	curve types.Curve
+}
+
+func (s bip340Scheme) Curve() types.Curve {
+	return s.curve
+}
+
+func (s bip340Scheme) Normalize(p types.Point) (types.Point, bool) {
+	// compressed points are prefixed with 3 if y is odd
+	if p.Encode()[0] == 3 {
+		return p.ScalarMul(s.curve.ScalarFromInt(1).Negate()), true
+	}
+
+	return p, false
+}
+
+func (bip340Scheme) Challenge(R, P types.Point, msg []byte) (types.Scalar, error) {
+	return secp256k1.BIP340Challenge(R, P, msg)
+}
+
+func (bip340Scheme) EncodeSignature(R types.Point, s types.Scalar) []byte {
+	r, err := secp256k1.XOnlyPublicKey(R)
+	if err != nil {
+		panic(err)
+	}
+
+	return append(r, s.Encode()...)
+}
+
+func (s bip340Scheme) DecodeSignature(sig []byte) (types.Point, types.Scalar, error) {
+	if len(sig) != secp256k1.BIP340SignatureSize {
+		return nil, nil, errInvalidInputLength
+	}
+
+	R, err := secp256k1.ParseXOnlyPublicKey(sig[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc, err := s.curve.DecodeToScalar(sig[32:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return R, sc, nil
+}
+
+func (bip340Scheme) Verify(P types.Point, msg, sig []byte) bool {
+	p, err := secp256k1.XOnlyPublicKey(P)
+	if err != nil {
+		return false
+	}
+
+	return secp256k1.VerifyBIP340(p, msg, sig)
+}
+
+type ed25519Scheme struct {
+	curve types.Curve
+}
+
+func (s ed25519Scheme) Curve() types.Curve {
+	return s.curve
+}
+
+func (ed25519Scheme) Normalize(p types.Point) (types.Point, bool) {
+	return p, false
+}
+
+func (ed25519Scheme) Challenge(R, P types.Point, msg []byte) (types.Scalar, error) {
+	return ed25519.SignatureChallenge(R, P, msg), nil
+}
+
+func (ed25519Scheme) EncodeSignature(R types.Point, s types.Scalar) []byte {
+	return append(R.Encode(), s.Encode()...)
+}
+
+func (s ed25519Scheme) DecodeSignature(sig []byte) (types.Point, types.Scalar, error) {
+	if len(sig) != stded25519.SignatureSize {
+		return nil, nil, errInvalidInputLength
+	}
+
+	R, err := s.curve.DecodeToPoint(sig[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc, err := s.curve.DecodeToScalar(sig[32:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return R, sc, nil
+}
+
+func (ed25519Scheme) Verify(P types.Point, msg, sig []byte) bool {
+	return stded25519.Verify(P.Encode(), msg, sig)
+}
@@ -0,0 +1,208 @@
+package adaptor
+
+import (
+	stded25519 "crypto/ed25519"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dleq "github.com/athanorlabs/go-dleq"
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+var schnorrSchemes = map[string]SchnorrScheme{
+	"BIP340":  BIP340,
+	"Ed25519": Ed25519,
+}
+
+func TestPreSignAndAdapt(t *testing.T) {
+	msg := []byte("claim transaction")
+	for name, scheme := range schnorrSchemes {
+		t.Run(name, func(t *testing.T) {
+			curve := scheme.Curve()
+
+			// R and P have odd y coordinates half of the time for BIP340
+			for i := 0; i < 16; i++ {
+				sk := curve.NewRandomScalar()
+				pk := curve.ScalarBaseMul(sk)
+				tt := curve.NewRandomScalar()
+				T := curve.ScalarBaseMul(tt)
+
+				pre, err := PreSign(scheme, sk, T, msg)
+				require.NoError(t, err)
+				err = VerifyPreSignature(scheme, pk, T, msg, pre)
+				require.NoError(t, err)
+
+				err = VerifyPreSignature(scheme, pk, T, []byte("refund transaction"), pre)
+				require.Error(t, err)
+				err = VerifyPreSignature(scheme, T, T, msg, pre)
+				require.Error(t, err)
+				err = VerifyPreSignature(scheme, pk, pk, msg, pre)
+				require.Error(t, err)
+
+				sig := Adapt(scheme, pre, tt)
+				require.True(t, scheme.Verify(pk, msg, sig))
+				require.False(t, scheme.Verify(pk, []byte("refund transaction"), sig))
+
+				// adapting with the wrong scalar doesn't produce a valid signature
+				bad := Adapt(scheme, pre, sk)
+				require.False(t, scheme.Verify(pk, msg, bad))
+
+				extracted, err := Extract(scheme, pre, sig, T)
+				require.NoError(t, err)
+				require.True(t, extracted.Eq(tt))
+
+				_, err = Extract(scheme, pre, bad, T)
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPreSignAndAdapt_IndependentVerification(t *testing.T) {
+	msg := []byte("claim transaction")
+
+	t.Run("BIP340", func(t *testing.T) {
+		curve := BIP340.Curve()
+		sk := curve.NewRandomScalar()
+		pub, err := secp256k1.XOnlyPublicKey(curve.ScalarBaseMul(sk))
+		require.NoError(t, err)
+		tt := curve.NewRandomScalar()
+
+		pre, err := PreSign(BIP340, sk, curve.ScalarBaseMul(tt), msg)
+		require.NoError(t, err)
+		sig := Adapt(BIP340, pre, tt)
+		require.True(t, secp256k1.VerifyBIP340(pub, msg, sig))
+	})
+
+	t.Run("Ed25519", func(t *testing.T) {
+		curve := Ed25519.Curve()
+		sk := curve.NewRandomScalar()
+		pub := curve.ScalarBaseMul(sk).Encode()
+		tt := curve.NewRandomScalar()
+
+		pre, err := PreSign(Ed25519, sk, curve.ScalarBaseMul(tt), msg)
+		require.NoError(t, err)
+		sig := Adapt(Ed25519, pre, tt)
+		require.True(t, stded25519.Verify(pub, msg, sig))
+	})
+}
+
+func TestPreSignature_Serialize(t *testing.T) {
+	msg := []byte("claim transaction")
+	for name, scheme := range schnorrSchemes {
+		t.Run(name, func(t *testing.T) {
+			curve := scheme.Curve()
+			sk := curve.NewRandomScalar()
+			pk := curve.ScalarBaseMul(sk)
+			T := curve.ScalarBaseMul(curve.NewRandomScalar())
+
+			pre, err := PreSign(scheme, sk, T, msg)
+			require.NoError(t, err)
+
+			ser := pre.Serialize()
+			deser := new(PreSignature)
+			err = deser.Deserialize(scheme, ser)
+			require.NoError(t, err)
+			err = VerifyPreSignature(scheme, pk, T, msg, deser)
+			require.NoError(t, err)
+
+			err = deser.Deserialize(scheme, ser[1:])
+			require.Error(t, err)
+
+			ser[len(ser)-2] ^= 1
+			err = deser.Deserialize(scheme, ser)
+			require.NoError(t, err)
+			err = VerifyPreSignature(scheme, pk, T, msg, deser)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestPreSign_DLEQ(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+
+	// Bob proves his key on both curves
+	x, err := dleq.GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	proof, err := dleq.NewProof(curveA, curveB, x)
+	require.NoError(t, err)
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	curves := []types.Curve{curveA, curveB}
+	indices := map[SchnorrScheme]int{BIP340: 0, Ed25519: 1}
+	hash := sha256.Sum256([]byte("claim transaction"))
+	for name, scheme := range schnorrSchemes {
+		t.Run(name, func(t *testing.T) {
+			curve := scheme.Curve()
+			index := indices[scheme]
+			T := proof.Commitments[index]
+
+			// Alice pre-signs Bob's claim transaction with Bob's key as the adaptor point
+			aliceSk := curve.NewRandomScalar()
+			alicePk := curve.ScalarBaseMul(aliceSk)
+			pre, err := PreSign(scheme, aliceSk, T, hash[:])
+			require.NoError(t, err)
+			err = VerifyPreSignatureWithProof(scheme, alicePk, hash[:], pre, curves, proof, index)
+			require.NoError(t, err)
+
+			// the index must be that of the scheme's curve
+			err = VerifyPreSignatureWithProof(scheme, alicePk, hash[:], pre, curves, proof, 1-index)
+			require.Error(t, err)
+			err = VerifyPreSignatureWithProof(scheme, alicePk, hash[:], pre, curves, proof, 2)
+			require.Error(t, err)
+
+			// Bob adapts the signature to claim, which publishes it
+			sig := Adapt(scheme, pre, curve.ScalarFromBytes(x))
+			require.True(t, scheme.Verify(alicePk, hash[:], sig))
+
+			// Alice extracts Bob's key, which is the witness on both curves
+			extracted, err := Extract(scheme, pre, sig, T)
+			require.NoError(t, err)
+			require.True(t, extracted.Eq(curve.ScalarFromBytes(x)))
+			require.True(t, curveA.ScalarBaseMul(curveA.ScalarFromBytes(x)).Equals(proof.CommitmentA))
+			require.True(t, curveB.ScalarBaseMul(curveB.ScalarFromBytes(x)).Equals(proof.CommitmentB))
+		})
+	}
+
+	// the pre-signature doesn't verify against another proof's commitment
+	other, err := dleq.NewProof(curveA, curveB, x[:len(x)-1])
+	require.NoError(t, err)
+	aliceSk := curveA.NewRandomScalar()
+	pre, err := PreSign(BIP340, aliceSk, proof.CommitmentA, hash[:])
+	require.NoError(t, err)
+	err = VerifyPreSignatureWithProof(BIP340, curveA.ScalarBaseMul(aliceSk), hash[:], pre, curves, other, 0)
+	require.Error(t, err)
+
+	// the proof is verified
+	invalid := *proof
+	invalid.CommitmentA = other.CommitmentA
+	invalid.Commitments = []types.Point{other.CommitmentA, proof.CommitmentB}
+	pre, err = PreSign(BIP340, aliceSk, other.CommitmentA, hash[:])
+	require.NoError(t, err)
+	err = VerifyPreSignatureWithProof(BIP340, curveA.ScalarBaseMul(aliceSk), hash[:], pre, curves, &invalid, 0)
+	require.Error(t, err)
+}
+
+func TestPreSign_ThreeCurveProof(t *testing.T) {
+	curves := []types.Curve{ed25519.NewCurve(), secp256k1.NewCurve(), secp256k1.NewCurve()}
+	x, err := dleq.GenerateSecretForCurves(curves...)
+	require.NoError(t, err)
+	proof, err := dleq.NewProofForCurves(curves, x, dleq.WithRadix(16))
+	require.NoError(t, err)
+
+	// the adaptor point is the proof's third commitment
+	hash := sha256.Sum256([]byte("claim transaction"))
+	sk := curves[2].NewRandomScalar()
+	pre, err := PreSign(BIP340, sk, proof.Commitments[2], hash[:])
+	require.NoError(t, err)
+	err = VerifyPreSignatureWithProof(BIP340, curves[2].ScalarBaseMul(sk), hash[:], pre, curves, proof, 2)
+	require.NoError(t, err)
+	err = VerifyPreSignatureWithProof(BIP340, curves[2].ScalarBaseMul(sk), hash[:], pre, curves, proof, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package adaptor
+
+import (
+	"errors"
+
+	"github.com/athanorlabs/go-dleq/chaumpedersen"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+const (
+	scalarLen = 32
+	// SignatureSize is the length of an encoded ECDSA signature.
+	SignatureSize = 2 * scalarLen
+)
+
+var errInvalidInputLength = errors.New("invalid input length")
+
+// Signature is an ECDSA signature with a low s value, as required by
+// Bitcoin's standardness rules.
+type Signature struct {
+	R, S types.Scalar
+}
+
+// Verify verifies the signature of the 32-byte message hash by the public key pk.
+func (s *Signature) Verify(pk types.Point, hash []byte) bool {
+	if len(hash) != 32 || s.R == nil || s.S == nil || s.R.IsZero() || s.S.IsZero() {
+		return false
+	}
+
+	// R = s^-1 * (m*G + r*X)
+	sInv := s.S.Inverse()
+	R := curve.ScalarBaseMul(hashToScalar(hash).Mul(sInv)).Add(pk.ScalarMul(s.R.Mul(sInv)))
+	if R.IsZero() {
+		return false
+	}
+
+	r, err := xCoordinate(R)
+	if err != nil {
+		return false
+	}
+
+	return r.Eq(s.R)
+}
+
+// Serialize encodes the signature in compact form, ie. r || s, each 32 bytes
+// in big-endian.
+func (s *Signature) Serialize() []byte {
+	return append(s.R.Encode(), s.S.Encode()...)
+}
+
+// Deserialize decodes a signature in compact form.
+func (s *Signature) Deserialize(in []byte) error {
+	if len(in) != SignatureSize {
+		return errInvalidInputLength
+	}
+
+	r, err := curve.DecodeToScalar(in[:scalarLen])
+	if err != nil {
+		return err
+	}
+
+	sc, err := curve.DecodeToScalar(in[scalarLen:])
+	if err != nil {
+		return err
+	}
+
+	s.R, s.S = r, sc
+	return nil
+}
+
+// Serialize encodes the encrypted signature.
+//
+// The encoding is: s' || Chaum-Pedersen proof, which contains R' and R.
+func (s *EncryptedSignature) Serialize() []byte {
+	return append(s.sHat.Encode(), s.proof.Serialize()...)
+}
+
+// Deserialize decodes an encrypted signature.
+func (s *EncryptedSignature) Deserialize(in []byte) error {
+	if len(in) < scalarLen {
+		return errInvalidInputLength
+	}
+
+	sHat, err := curve.DecodeToScalar(in[:scalarLen])
+	if err != nil {
+		return err
+	}
+
+	proof := new(chaumpedersen.Proof)
+	err = proof.Deserialize(curve, in[scalarLen:])
+	if err != nil {
+		return err
+	}
+
+	s.sHat = sHat
+	s.proof = proof
+	return nil
+}
@@ -0,0 +1,135 @@
+package dleq
+
+import (
+	"errors"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+var (
+	errInvalidAffineRelation = errors.New("affine relation coefficient a must be non-zero")
+	errAffineCommitment      = errors.New("CommitmentB must be a*x_A*G_B + b*G_B")
+)
+
+// AffineProof proves that the witnesses x_A of CommitmentA and x_B of
+// CommitmentB satisfy x_B = a*x_A + b, for the public scalars A and B on
+// curve B.
+//
+// It contains a proof that X_A = x_A*G_A and x_A*G_B have the same discrete
+// logarithm; the verifier derives X_B = a*(x_A*G_B) + b*G_B. Callers must
+// check that A and B are the relation they expect.
+type AffineProof struct {
+	CommitmentA, CommitmentB Point
+	// A and B are the coefficients of the relation.
+	A, B Scalar
+	// proof is the proof for x_A on both curves.
+	proof *Proof
+}
+
+// NewAffineProof returns a new proof that the witnesses x_A of CommitmentA and
+// x_B of CommitmentB satisfy x_B = a*x_A + b, for the public scalars a and b
+// on curve B. The witness x = x_A must be in little-endian and smaller than the
+// minimum order of the two curves, or smaller than 2^n if a bit length n is
+// set with WithBits.
+//
+// The relation holds modulo the order l_B of curve B. Since the proof shows
+// that x_A < 2^n, the relation also holds over the integers if
+// a*(2^n - 1) + b < l_B, taking a and b as integers in [0, l_B); otherwise,
+// x_B may have wrapped around l_B.
+func NewAffineProof(curveA, curveB Curve, x []byte, a, b Scalar, opts ...Option) (*AffineProof, error) {
+	if a == nil || b == nil || a.IsZero() {
+		return nil, errInvalidAffineRelation
+	}
+
+	proof, err := NewProof(curveA, curveB, x, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AffineProof{
+		CommitmentA: proof.CommitmentA,
+		CommitmentB: affineCommitment(curveB, proof.CommitmentB, a, b),
+		A:           a,
+		B:           b,
+		proof:       proof,
+	}, nil
+}
+
+// affineCommitment returns a*inner + b*G_B, where inner is x_A*G_B.
+func affineCommitment(curveB Curve, inner Point, a, b Scalar) Point {
+	return inner.ScalarMul(a).Add(curveB.ScalarBaseMul(b))
+}
+
+// Bits returns the bit length n of the witness x_A, ie. the proof shows that
+// x_A < 2^n.
+func (p *AffineProof) Bits() uint64 {
+	return p.proof.Bits()
+}
+
+// Verify verifies that the witnesses of CommitmentA and CommitmentB satisfy
+// x_B = a*x_A + b modulo the order of curve B, for the proof's A and B.
+// By default, the proof must be for a witness of the full bit length; see WithMinBits.
+func (p *AffineProof) Verify(curveA, curveB Curve, opts ...VerifyOption) error {
+	if p.A == nil || p.B == nil || p.A.IsZero() {
+		return errInvalidAffineRelation
+	}
+
+	if p.proof == nil {
+		return errInvalidNumCommitments
+	}
+
+	err := p.proof.Verify(curveA, curveB, opts...)
+	if err != nil {
+		return err
+	}
+
+	if p.CommitmentA == nil || !p.CommitmentA.Equals(p.proof.CommitmentA) {
+		return errCommitmentMismatch
+	}
+
+	if p.CommitmentB == nil || !p.CommitmentB.Equals(affineCommitment(curveB, p.proof.CommitmentB, p.A, p.B)) {
+		return errAffineCommitment
+	}
+
+	return nil
+}
+
+// Serialize encodes the proof.
+//
+// The encoding is: a || b || the encoding of the proof for x_A on both curves,
+// where a and b are encoded as scalars on curve B.
+func (p *AffineProof) Serialize() []byte {
+	b := append([]byte{}, p.A.Encode()...)
+	b = append(b, p.B.Encode()...)
+	return append(b, p.proof.Serialize()...)
+}
+
+// Deserialize decodes the proof for the given curves.
+func (p *AffineProof) Deserialize(curveA, curveB types.Curve, in []byte) error {
+	d := newDecoder(in)
+	a, err := d.readScalar(curveB)
+	if err != nil {
+		return err
+	}
+
+	b, err := d.readScalar(curveB)
+	if err != nil {
+		return err
+	}
+
+	if a.IsZero() {
+		return errInvalidAffineRelation
+	}
+
+	proof := new(Proof)
+	err = proof.Deserialize(curveA, curveB, in[2*curveB.ScalarSize():])
+	if err != nil {
+		return err
+	}
+
+	p.CommitmentA = proof.CommitmentA
+	p.CommitmentB = affineCommitment(curveB, proof.CommitmentB, a, b)
+	p.A, p.B = a, b
+	p.proof = proof
+	return nil
+}
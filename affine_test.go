@@ -0,0 +1,58 @@
+package dleq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+)
+
+func TestAffineProof(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	a, b := curveB.ScalarFromInt(8), curveB.NewRandomScalar()
+
+	proof, err := NewAffineProof(curveA, curveB, x, a, b, WithRadix(4))
+	require.NoError(t, err)
+	xB := curveB.ScalarFromBytes(x).Mul(a).Add(b)
+	require.True(t, curveB.ScalarBaseMul(xB).Equals(proof.CommitmentB))
+	require.True(t, curveA.ScalarBaseMul(curveA.ScalarFromBytes(x)).Equals(proof.CommitmentA))
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(AffineProof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+	require.True(t, deser.A.Eq(a))
+	require.True(t, deser.B.Eq(b))
+	require.True(t, deser.CommitmentB.Equals(proof.CommitmentB))
+	require.Equal(t, ser, deser.Serialize())
+
+	// an affine proof isn't a valid Proof
+	err = new(Proof).Deserialize(curveA, curveB, ser)
+	require.Error(t, err)
+
+	// the commitments must match the relation
+	deser.B = b.Add(curveB.ScalarFromInt(1))
+	err = deser.Verify(curveA, curveB)
+	require.ErrorIs(t, err, errAffineCommitment)
+	deser.B = b
+	deser.CommitmentA = curveA.BasePoint()
+	err = deser.Verify(curveA, curveB)
+	require.ErrorIs(t, err, errCommitmentMismatch)
+	deser.A = curveB.ScalarFromInt(0)
+	err = deser.Verify(curveA, curveB)
+	require.ErrorIs(t, err, errInvalidAffineRelation)
+
+	_, err = NewAffineProof(curveA, curveB, x, curveB.ScalarFromInt(0), b)
+	require.ErrorIs(t, err, errInvalidAffineRelation)
+	err = new(AffineProof).Deserialize(curveA, curveB, ser[:len(ser)-1])
+	require.Error(t, err)
+}
@@ -0,0 +1,184 @@
+package dleq
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const cacheKeyDomain = "go-dleq/verification-cache"
+
+// VerificationCache stores the keys of proofs that have been successfully
+// verified. Implementations must be safe for concurrent use; a store shared
+// between processes (eg. backed by a database) should treat lookup failures
+// as a miss.
+type VerificationCache interface {
+	Contains(key [32]byte) bool
+	Add(key [32]byte)
+}
+
+// CachingVerifier verifies proofs, skipping verification of proofs that have
+// already been verified successfully for the same curves and context.
+// Failed verifications are never cached.
+type CachingVerifier struct {
+	cache VerificationCache
+}
+
+// NewCachingVerifier returns a new CachingVerifier backed by the given cache.
+func NewCachingVerifier(cache VerificationCache) *CachingVerifier {
+	return &CachingVerifier{
+		cache: cache,
+	}
+}
+
+// Verify verifies the proof against the given curves, returning early if an
+// identical proof has already been verified for the same curves and context.
+// The context is opaque to the verifier and only used to separate cache entries.
+func (v *CachingVerifier) Verify(
+	curveA, curveB Curve,
+	proof *Proof,
+	context []byte,
+	opts ...VerifyOption,
+) error {
+	// the parameters are checked for cached proofs too, as they depend on the
+	// options the proof is verified with.
+	curves := []Curve{curveA, curveB}
+	err := proof.checkParameters(curves, opts)
+	if err != nil {
+		return err
+	}
+
+	key := cacheKey(curves, proof, context)
+	if v.cache.Contains(key) {
+		return nil
+	}
+
+	err = proof.Verify(curveA, curveB, opts...)
+	if err != nil {
+		return err
+	}
+
+	v.cache.Add(key)
+	return nil
+}
+
+// cacheKey returns the hash of the canonical encoding of the proof, along with
+// the generators of the curves and the context.
+func cacheKey(curves []Curve, proof *Proof, context []byte) [32]byte {
+	h := sha3.New256()
+	writeLengthPrefixed := func(b []byte) {
+		var l [8]byte
+		binary.LittleEndian.PutUint64(l[:], uint64(len(b)))
+		_, _ = h.Write(l[:])
+		_, _ = h.Write(b)
+	}
+
+	writeLengthPrefixed([]byte(cacheKeyDomain))
+	for _, curve := range curves {
+		writeLengthPrefixed(curve.BasePoint().Encode())
+		writeLengthPrefixed(curve.AltBasePoint().Encode())
+	}
+	writeLengthPrefixed(context)
+	writeLengthPrefixed(proof.Serialize())
+
+	var key [32]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// LRUCache is an in-memory VerificationCache which holds up to a fixed number
+// of entries, each of which expires after a fixed duration.
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	entries *list.List
+	index   map[[32]byte]*list.Element
+}
+
+type lruEntry struct {
+	key     [32]byte
+	expires time.Time
+}
+
+var _ VerificationCache = &LRUCache{}
+
+// NewLRUCache returns a new LRUCache holding at most size entries, which
+// must be positive. If ttl is zero, entries do not expire.
+func NewLRUCache(size int, ttl time.Duration) (*LRUCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("cache size must be positive, got %d", size)
+	}
+
+	if ttl < 0 {
+		return nil, errors.New("cache ttl must not be negative")
+	}
+
+	return &LRUCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: list.New(),
+		index:   make(map[[32]byte]*list.Element),
+	}, nil
+}
+
+// Contains returns whether the key is in the cache and has not expired.
+func (c *LRUCache) Contains(key [32]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, has := c.index[key]
+	if !has {
+		return false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if c.ttl != 0 && !c.now().Before(entry.expires) {
+		c.entries.Remove(el)
+		delete(c.index, key)
+		return false
+	}
+
+	c.entries.MoveToFront(el)
+	return true
+}
+
+// Add adds the key to the cache, evicting the least recently used entry if
+// the cache is full.
+func (c *LRUCache) Add(key [32]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if el, has := c.index[key]; has {
+		el.Value.(*lruEntry).expires = expires
+		c.entries.MoveToFront(el)
+		return
+	}
+
+	c.index[key] = c.entries.PushFront(&lruEntry{
+		key:     key,
+		expires: expires,
+	})
+
+	if c.entries.Len() > c.size {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.index, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// which have not yet been evicted.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
@@ -0,0 +1,107 @@
+package dleq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+)
+
+type countingCache struct {
+	*LRUCache
+	adds int
+}
+
+func (c *countingCache) Add(key [32]byte) {
+	c.adds++
+	c.LRUCache.Add(key)
+}
+
+func TestCachingVerifier(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	proof, err := NewProof(curveA, curveB, x)
+	require.NoError(t, err)
+
+	lru, err := NewLRUCache(8, time.Minute)
+	require.NoError(t, err)
+	cache := &countingCache{LRUCache: lru}
+	verifier := NewCachingVerifier(cache)
+
+	err = verifier.Verify(curveA, curveB, proof, []byte("ctx"))
+	require.NoError(t, err)
+	require.Equal(t, 1, cache.adds)
+
+	// cache hit
+	err = verifier.Verify(curveA, curveB, proof, []byte("ctx"))
+	require.NoError(t, err)
+	require.Equal(t, 1, cache.adds)
+
+	// different context is a different entry
+	err = verifier.Verify(curveA, curveB, proof, []byte("other"))
+	require.NoError(t, err)
+	require.Equal(t, 2, cache.adds)
+
+	// invalid proofs are not cached
+	invalid := *proof
+	invalid.proofs = append([]bitProof{}, proof.proofs...)
+	invalid.proofs[0], invalid.proofs[1] = invalid.proofs[1], invalid.proofs[0]
+	err = verifier.Verify(curveA, curveB, &invalid, []byte("ctx"))
+	require.Error(t, err)
+	require.Equal(t, 2, cache.adds)
+	require.Equal(t, 2, cache.Len())
+}
+
+func TestLRUCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache, err := NewLRUCache(2, time.Second)
+	require.NoError(t, err)
+	cache.now = func() time.Time { return now }
+
+	keys := [][32]byte{{1}, {2}, {3}}
+	cache.Add(keys[0])
+	cache.Add(keys[1])
+	require.True(t, cache.Contains(keys[0]))
+
+	// keys[1] is least recently used and is evicted
+	cache.Add(keys[2])
+	require.True(t, cache.Contains(keys[0]))
+	require.False(t, cache.Contains(keys[1]))
+	require.True(t, cache.Contains(keys[2]))
+
+	now = now.Add(time.Second)
+	require.False(t, cache.Contains(keys[0]))
+	require.False(t, cache.Contains(keys[2]))
+	require.Equal(t, 0, cache.Len())
+
+	_, err = NewLRUCache(0, 0)
+	require.Error(t, err)
+	_, err = NewLRUCache(-1, 0)
+	require.Error(t, err)
+	_, err = NewLRUCache(1, -time.Second)
+	require.Error(t, err)
+}
+
+func TestCachingVerifier_MinBits(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := generateRandomBits(32)
+	require.NoError(t, err)
+	proof, err := NewProof(curveA, curveB, x, WithBits(32))
+	require.NoError(t, err)
+
+	cache, err := NewLRUCache(8, 0)
+	require.NoError(t, err)
+	verifier := NewCachingVerifier(cache)
+	err = verifier.Verify(curveA, curveB, proof, nil, WithMinBits(32))
+	require.NoError(t, err)
+
+	// a cached proof is still rejected if its bit length isn't accepted
+	err = verifier.Verify(curveA, curveB, proof, nil)
+	require.Error(t, err)
+}
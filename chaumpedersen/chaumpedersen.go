@@ -0,0 +1,222 @@
+// Package chaumpedersen implements Chaum-Pedersen proofs that two points on the
+// same curve have the same discrete logarithm with respect to two generators,
+// ie. that log_G(X) = log_H(Y).
+//
+// The generators are arbitrary and are not part of the proof; the verifier must
+// supply the same generators as the prover. Points are not checked to be in the
+// prime-order subgroup, so on curves with a cofactor (eg. ed25519) the caller
+// must check this if required, as for Monero key images.
+package chaumpedersen
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+const challengeDomain = "go-dleq/chaum-pedersen"
+
+var errInvalidProof = errors.New("invalid proof")
+
+// Proof is a proof that log_G(X) = log_H(Y) for the public points X and Y.
+type Proof struct {
+	X, Y types.Point
+	// R1 = k*G, R2 = k*H
+	r1, r2 types.Point
+	// s = k + c*x
+	s types.Scalar
+}
+
+// NewProof returns a new proof that X = x*G and Y = x*H have the same discrete
+// logarithm with respect to G and H.
+func NewProof(curve types.Curve, G, H types.Point, x types.Scalar) (*Proof, error) {
+	if x.IsZero() {
+		return nil, errors.New("witness must not be zero")
+	}
+
+	X := G.ScalarMul(x)
+	Y := H.ScalarMul(x)
+
+	k := curve.NewRandomScalar()
+	r1 := G.ScalarMul(k)
+	r2 := H.ScalarMul(k)
+
+	c, err := challenge(curve, G, H, X, Y, r1, r2)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Proof{
+		X:  X,
+		Y:  Y,
+		r1: r1,
+		r2: r2,
+		s:  k.Add(c.Mul(x)),
+	}, nil
+}
+
+// Verify verifies the proof against the given generators.
+func (p *Proof) Verify(curve types.Curve, G, H types.Point) error {
+	c, err := p.challenge(curve, G, H)
+	if err != nil {
+		return err
+	}
+
+	// s*G = R1 + c*X
+	if !G.ScalarMul(p.s).Equals(p.r1.Add(p.X.ScalarMul(c))) {
+		return errInvalidProof
+	}
+
+	// s*H = R2 + c*Y
+	if !H.ScalarMul(p.s).Equals(p.r2.Add(p.Y.ScalarMul(c))) {
+		return errInvalidProof
+	}
+
+	return nil
+}
+
+func (p *Proof) challenge(curve types.Curve, G, H types.Point) (types.Scalar, error) {
+	if p.X == nil || p.Y == nil || p.r1 == nil || p.r2 == nil || p.s == nil {
+		return nil, errors.New("proof is missing fields")
+	}
+
+	return challenge(curve, G, H, p.X, p.Y, p.r1, p.r2)
+}
+
+// challenge hashes the generators, the statement and the nonce commitments to a scalar.
+func challenge(curve types.Curve, points ...types.Point) (types.Scalar, error) {
+	preimage := []byte(challengeDomain)
+	for _, p := range points {
+		preimage = append(preimage, p.Encode()...)
+	}
+
+	return curve.HashToScalar(preimage)
+}
+
+// Batch verifies many proofs on the same curve at once.
+//
+// The verification equations of each proof are weighted by random scalars and
+// summed into a single check, with the terms of generators shared between
+// proofs combined. If the curve implements types.MultiScalarMuler, the sum is
+// computed with a single multi-scalar multiplication, which is considerably
+// faster than verifying each proof; otherwise, batching has no benefit.
+// Of the curves in this module, only ed25519 implements it, so on secp256k1 a
+// batch is no faster than verifying each proof in turn.
+//
+// On curves with a cofactor, a proof whose points have a small-order component
+// may pass batch verification while failing individual verification, so the
+// points must be checked to be in the prime-order subgroup beforehand.
+type Batch struct {
+	curve   types.Curve
+	entries []batchEntry
+}
+
+type batchEntry struct {
+	G, H  types.Point
+	proof *Proof
+}
+
+// NewBatch returns a new, empty batch for the given curve.
+func NewBatch(curve types.Curve) *Batch {
+	return &Batch{
+		curve: curve,
+	}
+}
+
+// Add adds the proof with the given generators to the batch.
+func (b *Batch) Add(G, H types.Point, proof *Proof) {
+	b.entries = append(b.entries, batchEntry{
+		G:     G,
+		H:     H,
+		proof: proof,
+	})
+}
+
+// Len returns the number of proofs in the batch.
+func (b *Batch) Len() int {
+	return len(b.entries)
+}
+
+// Verify verifies all the proofs in the batch. If the batch is invalid, each
+// proof is verified individually to find the first invalid proof.
+// It's only faster than verifying each proof if the curve implements
+// types.MultiScalarMuler, which secp256k1 doesn't.
+func (b *Batch) Verify() error {
+	err := b.verify()
+	if err == nil {
+		return nil
+	}
+
+	for i, e := range b.entries {
+		err := e.proof.Verify(b.curve, e.G, e.H)
+		if err != nil {
+			return fmt.Errorf("failed to verify proof %d: %w", i, err)
+		}
+	}
+
+	return err
+}
+
+// verify checks that
+// sum_i (w_i*s_i*G_i + v_i*s_i*H_i - w_i*R1_i - w_i*c_i*X_i - v_i*R2_i - v_i*c_i*Y_i) = 0
+// for random weights w_i, v_i.
+func (b *Batch) verify() error {
+	if len(b.entries) == 0 {
+		return nil
+	}
+
+	var (
+		scalars []types.Scalar
+		points  []types.Point
+	)
+
+	// index of each distinct generator in points, keyed by its encoding
+	generators := make(map[string]int)
+	addGenerator := func(g types.Point, coeff types.Scalar) {
+		key := string(g.Encode())
+		if i, has := generators[key]; has {
+			scalars[i] = scalars[i].Add(coeff)
+			return
+		}
+		generators[key] = len(points)
+		scalars = append(scalars, coeff)
+		points = append(points, g)
+	}
+
+	for _, e := range b.entries {
+		c, err := e.proof.challenge(b.curve, e.G, e.H)
+		if err != nil {
+			return err
+		}
+
+		w := b.curve.NewRandomScalar()
+		v := b.curve.NewRandomScalar()
+		addGenerator(e.G, w.Mul(e.proof.s))
+		addGenerator(e.H, v.Mul(e.proof.s))
+		scalars = append(scalars,
+			w.Negate(), w.Mul(c).Negate(),
+			v.Negate(), v.Mul(c).Negate(),
+		)
+		points = append(points, e.proof.r1, e.proof.X, e.proof.r2, e.proof.Y)
+	}
+
+	if !multiScalarMul(b.curve, scalars, points).IsZero() {
+		return errInvalidProof
+	}
+
+	return nil
+}
+
+// multiScalarMul returns the sum of scalars[i]*points[i].
+func multiScalarMul(curve types.Curve, scalars []types.Scalar, points []types.Point) types.Point {
+	if msm, ok := curve.(types.MultiScalarMuler); ok {
+		return msm.VarTimeMultiScalarMul(scalars, points)
+	}
+
+	sum := points[0].ScalarMul(scalars[0])
+	for i := 1; i < len(points); i++ {
+		sum = sum.Add(points[i].ScalarMul(scalars[i]))
+	}
+	return sum
+}
@@ -0,0 +1,72 @@
+package chaumpedersen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+func TestProveAndVerify(t *testing.T) {
+	for _, curve := range []types.Curve{secp256k1.NewCurve(), ed25519.NewCurve()} {
+		G, H := curve.BasePoint(), curve.AltBasePoint()
+		x := curve.NewRandomScalar()
+		proof, err := NewProof(curve, G, H, x)
+		require.NoError(t, err)
+		require.True(t, curve.ScalarBaseMul(x).Equals(proof.X))
+		err = proof.Verify(curve, G, H)
+		require.NoError(t, err)
+
+		ser := proof.Serialize()
+		deser := new(Proof)
+		err = deser.Deserialize(curve, ser)
+		require.NoError(t, err)
+		require.Equal(t, ser, deser.Serialize())
+		err = deser.Verify(curve, G, H)
+		require.NoError(t, err)
+
+		// the generators are bound to the proof
+		err = proof.Verify(curve, H, G)
+		require.Error(t, err)
+
+		// Y has a different discrete logarithm
+		deser.Y = deser.Y.Add(H)
+		err = deser.Verify(curve, G, H)
+		require.Error(t, err)
+
+		err = deser.Deserialize(curve, ser[:len(ser)-1])
+		require.Error(t, err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	for _, curve := range []types.Curve{secp256k1.NewCurve(), ed25519.NewCurve()} {
+		G := curve.BasePoint()
+		batch := NewBatch(curve)
+		proofs := make([]*Proof, 8)
+		for i := range proofs {
+			// each proof has a different second generator, as for key images
+			H := curve.ScalarBaseMul(curve.NewRandomScalar())
+			var err error
+			proofs[i], err = NewProof(curve, G, H, curve.NewRandomScalar())
+			require.NoError(t, err)
+			batch.Add(G, H, proofs[i])
+		}
+		require.Equal(t, 8, batch.Len())
+		err := batch.Verify()
+		require.NoError(t, err)
+
+		invalid := *proofs[5]
+		invalid.s = invalid.s.Add(curve.ScalarFromInt(1))
+		batch.entries[5].proof = &invalid
+		err = batch.Verify()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to verify proof 5")
+
+		err = NewBatch(curve).Verify()
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,56 @@
+package chaumpedersen
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+const proofVersion byte = 1
+
+var errInvalidInputLength = errors.New("invalid input length")
+
+// Serialize encodes the proof.
+//
+// The encoding is: version (1 byte) || X || Y || R1 || R2 || s.
+func (p *Proof) Serialize() []byte {
+	b := []byte{proofVersion}
+	b = append(b, p.X.Encode()...)
+	b = append(b, p.Y.Encode()...)
+	b = append(b, p.r1.Encode()...)
+	b = append(b, p.r2.Encode()...)
+	b = append(b, p.s.Encode()...)
+	return b
+}
+
+// Deserialize decodes the proof for the given curve.
+func (p *Proof) Deserialize(curve types.Curve, in []byte) error {
+	pointLen := curve.CompressedPointSize()
+	if len(in) != 1+4*pointLen+curve.ScalarSize() {
+		return errInvalidInputLength
+	}
+
+	if in[0] != proofVersion {
+		return fmt.Errorf("unsupported proof version %d", in[0])
+	}
+
+	points := make([]types.Point, 4)
+	for i := range points {
+		start := 1 + i*pointLen
+		var err error
+		points[i], err = curve.DecodeToPoint(in[start : start+pointLen])
+		if err != nil {
+			return err
+		}
+	}
+
+	s, err := curve.DecodeToScalar(in[1+4*pointLen:])
+	if err != nil {
+		return err
+	}
+
+	p.X, p.Y, p.r1, p.r2 = points[0], points[1], points[2], points[3]
+	p.s = s
+	return nil
+}
@@ -0,0 +1,108 @@
+package dleq
+
+import (
+	"errors"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// CommitmentEqualityProof proves that Pedersen commitments on two curves,
+// x*G_A + r_A*H_A and x*G_B + r_B*H_B, commit to the same value x, without
+// revealing x*G_A or x*G_B. H is the AltBasePoint of each curve.
+//
+// The digit commitments on each curve are generated so that they sum to the
+// Pedersen commitment instead of to x*G, so the ring signatures of each digit
+// prove knowledge of both x and the blinders.
+type CommitmentEqualityProof struct {
+	CommitmentA, CommitmentB Point
+	digitProofs
+}
+
+// PedersenCommitment returns the Pedersen commitment x*G + r*H on the given
+// curve, where H is the curve's AltBasePoint. x must be in little-endian.
+func PedersenCommitment(curve Curve, x []byte, blinder Scalar) Point {
+	xG := curve.ScalarBaseMul(scalarFromWitness(curve, x))
+	return xG.Add(curve.ScalarMul(blinder, curve.AltBasePoint()))
+}
+
+// NewCommitmentEqualityProof returns a new proof that the Pedersen commitments
+// to x with the given blinders on each curve commit to the same value.
+// The blinders must be independent and uniformly random for the commitments
+// to be hiding. The witness x must be in little-endian and smaller than the
+// minimum order of the two curves, or smaller than 2^n if a bit length n is
+// set with WithBits.
+func NewCommitmentEqualityProof(
+	curveA, curveB Curve,
+	x []byte,
+	blinderA, blinderB Scalar,
+	opts ...Option,
+) (*CommitmentEqualityProof, error) {
+	if blinderA == nil || blinderB == nil {
+		return nil, errors.New("blinders must not be nil")
+	}
+
+	curves := []Curve{curveA, curveB}
+	o, err := newOptions(opts, minBitSize(curves))
+	if err != nil {
+		return nil, err
+	}
+	if len(o.signatureSchemes) != 0 {
+		return nil, errSignatureSchemes
+	}
+
+	x, err = normalizeWitness(x, o.bits)
+	if err != nil {
+		return nil, err
+	}
+
+	commitments := []Point{
+		PedersenCommitment(curveA, x, blinderA),
+		PedersenCommitment(curveB, x, blinderB),
+	}
+
+	digits, err := newDigitProofs(curves, x, o, []Scalar{blinderA, blinderB}, commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommitmentEqualityProof{
+		CommitmentA: commitments[0],
+		CommitmentB: commitments[1],
+		digitProofs: *digits,
+	}, nil
+}
+
+// Verify verifies the proof is valid against the given curves.
+// By default, the proof must be for a value of the full bit length; see WithMinBits.
+func (p *CommitmentEqualityProof) Verify(curveA, curveB Curve, opts ...VerifyOption) error {
+	if p.CommitmentA == nil || p.CommitmentB == nil {
+		return errInvalidNumCommitments
+	}
+
+	curves := []Curve{curveA, curveB}
+	err := p.digitProofs.checkParameters(curves, opts)
+	if err != nil {
+		return err
+	}
+
+	return p.digitProofs.verify(curves, []Point{p.CommitmentA, p.CommitmentB})
+}
+
+// Serialize encodes the proof. The encoding is the same as that of a Proof,
+// without the signatures.
+func (p *CommitmentEqualityProof) Serialize() []byte {
+	return p.digitProofs.encode([]Point{p.CommitmentA, p.CommitmentB})
+}
+
+// Deserialize decodes the proof for the given curves.
+// The curves must match those passed into `NewCommitmentEqualityProof`.
+func (p *CommitmentEqualityProof) Deserialize(curveA, curveB types.Curve, in []byte) error {
+	curves := []types.Curve{curveA, curveB}
+	_, commitments, err := p.digitProofs.decode(newDecoder(in), curves, minBitSize(curves), len(in))
+	if err != nil {
+		return err
+	}
+
+	p.CommitmentA, p.CommitmentB = commitments[0], commitments[1]
+	return nil
+}
@@ -0,0 +1,45 @@
+package dleq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+)
+
+func TestCommitmentEqualityProof(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	rA, rB := curveA.NewRandomScalar(), curveB.NewRandomScalar()
+
+	proof, err := NewCommitmentEqualityProof(curveA, curveB, x, rA, rB, WithRadix(4))
+	require.NoError(t, err)
+	require.True(t, PedersenCommitment(curveA, x, rA).Equals(proof.CommitmentA))
+	require.True(t, PedersenCommitment(curveB, x, rB).Equals(proof.CommitmentB))
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(CommitmentEqualityProof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	// the proof doesn't hold for a commitment to a different value
+	y := append([]byte{}, x...)
+	y[0] ^= 1
+	deser.CommitmentB = PedersenCommitment(curveB, y, rB)
+	err = deser.Verify(curveA, curveB)
+	require.Error(t, err)
+
+	// or with a different blinder
+	deser.CommitmentB = PedersenCommitment(curveB, x, rB.Add(curveB.ScalarFromInt(1)))
+	err = deser.Verify(curveA, curveB)
+	require.Error(t, err)
+}
@@ -1,12 +1,15 @@
 package dleq
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/sha3"
 
 	"github.com/athanorlabs/go-dleq/ed25519"
 	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
 )
 
 func TestWitnessSize(t *testing.T) {
@@ -17,34 +20,54 @@ func TestWitnessSize(t *testing.T) {
 	err = checkWitnessSize(x, min(curveA.BitSize(), curveB.BitSize()))
 	require.NoError(t, err)
 
-	x = [32]byte{}
+	x = make([]byte, 32)
 	x[31] = 0xff
 	err = checkWitnessSize(x, 255)
 	require.Error(t, err)
 
-	x = [32]byte{}
+	x = make([]byte, 32)
 	x[30] = 0xff
 	err = checkWitnessSize(x, 247)
 	require.Error(t, err)
 
-	x = [32]byte{}
+	x = make([]byte, 32)
 	x[30] = 0b00010101
 	err = checkWitnessSize(x, 244)
 	require.Error(t, err)
 	err = checkWitnessSize(x, 245)
 	require.NoError(t, err)
+
+	x = make([]byte, 32)
+	x[16] = 1
+	err = checkWitnessSize(x, 128)
+	require.Error(t, err)
+	err = checkWitnessSize(x, 129)
+	require.NoError(t, err)
+
+	for _, bits := range []uint64{1, 64, 100, 252} {
+		x, err = generateRandomBits(bits)
+		require.NoError(t, err)
+		err = checkWitnessSize(x, bits)
+		require.NoError(t, err)
+	}
 }
 
 func TestGenerateCommitments(t *testing.T) {
 	curve := secp256k1.NewCurve()
 	x, err := generateRandomBits(curve.BitSize())
 	require.NoError(t, err)
-	commitments, err := generateCommitments(curve, x[:], curve.BitSize())
+	commitments, err := generateCommitments(curve, x[:], curve.BitSize(), 2, nil)
 	require.NoError(t, err)
 	require.Equal(t, int(curve.BitSize()), len(commitments))
 
 	X := curve.ScalarBaseMul(curve.ScalarFromBytes(x))
-	err = verifyCommitmentsSum(curve, commitments, X)
+	err = verifyCommitmentsSum(curve, commitments, X, 2)
+	require.NoError(t, err)
+
+	commitments, err = generateCommitments(curve, x[:], curve.BitSize(), 8, nil)
+	require.NoError(t, err)
+	require.Equal(t, 85, len(commitments))
+	err = verifyCommitmentsSum(curve, commitments, X, 8)
 	require.NoError(t, err)
 }
 
@@ -52,16 +75,22 @@ func TestGenerateRingSignature(t *testing.T) {
 	curve := secp256k1.NewCurve()
 	x, err := generateRandomBits(curve.BitSize())
 	require.NoError(t, err)
-	commitmentsA, err := generateCommitments(curve, x[:], curve.BitSize())
+	commitmentsA, err := generateCommitments(curve, x[:], curve.BitSize(), 2, nil)
 	require.NoError(t, err)
 	require.Equal(t, int(curve.BitSize()), len(commitmentsA))
-	commitmentsB, err := generateCommitments(curve, x[:], curve.BitSize())
+	commitmentsB, err := generateCommitments(curve, x[:], curve.BitSize(), 2, nil)
 	require.NoError(t, err)
 	require.Equal(t, int(curve.BitSize()), len(commitmentsB))
 
 	for i := 0; i < int(curve.BitSize()); i++ {
 		bit := getBit(x[:], uint64(i))
-		_, err := generateRingSignature(curve, curve, bit, commitmentsA[i], commitmentsB[i])
+		_, err := generateRingSignature(
+			[]Curve{curve, curve},
+			proofVersion1,
+			nil,
+			uint64(bit), 2,
+			[]commitment{commitmentsA[i], commitmentsB[i]},
+		)
 		require.NoError(t, err)
 	}
 }
@@ -76,3 +105,187 @@ func TestProveAndVerify(t *testing.T) {
 	err = proof.Verify(curveA, curveB)
 	require.NoError(t, err)
 }
+
+func TestProveAndVerify_Radix(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+
+	for _, radix := range []uint64{4, 8, 32} {
+		proof, err := NewProof(curveA, curveB, x, WithRadix(radix))
+		require.NoError(t, err)
+		require.Equal(t, int(numDigits(252, radix)), len(proof.proofs))
+		err = proof.Verify(curveA, curveB)
+		require.NoError(t, err)
+	}
+
+	_, err = NewProof(curveA, curveB, x, WithRadix(3))
+	require.Error(t, err)
+	_, err = NewProof(curveA, curveB, x, WithRadix(256))
+	require.Error(t, err)
+}
+
+func TestGetDigit(t *testing.T) {
+	x := [32]byte{0b10110110, 0b00000011}
+	require.Equal(t, uint64(0b10), getDigit(x[:], 10, 4, 0))
+	require.Equal(t, uint64(0b110), getDigit(x[:], 10, 8, 0))
+	require.Equal(t, uint64(0b110), getDigit(x[:], 10, 8, 1))
+	require.Equal(t, uint64(0b1), getDigit(x[:], 10, 8, 3))
+	require.Equal(t, uint64(2), digitRingSize(10, 8, 3))
+	require.Equal(t, uint64(4), numDigits(10, 8))
+}
+
+func TestProveAndVerify_Bits(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := generateRandomBits(64)
+	require.NoError(t, err)
+
+	proof, err := NewProof(curveA, curveB, x, WithBits(64))
+	require.NoError(t, err)
+	require.Equal(t, 64, len(proof.proofs))
+
+	// by default, the full bit length is required
+	err = proof.Verify(curveA, curveB)
+	require.Error(t, err)
+	err = proof.Verify(curveA, curveB, WithMinBits(65))
+	require.Error(t, err)
+	err = proof.Verify(curveA, curveB, WithMinBits(64))
+	require.NoError(t, err)
+
+	x = append(x, 1)
+	_, err = NewProof(curveA, curveB, x, WithBits(64))
+	require.Error(t, err)
+	_, err = NewProof(curveA, curveB, x, WithBits(253))
+	require.Error(t, err)
+}
+
+func TestProveAndVerify_ThreeCurves(t *testing.T) {
+	curves := []Curve{secp256k1.NewCurve(), ed25519.NewCurve(), secp256k1.NewCurve()}
+	x, err := GenerateSecretForCurves(curves...)
+	require.NoError(t, err)
+
+	proof, err := NewProofForCurves(curves, x, WithRadix(4))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(proof.Commitments))
+	require.True(t, proof.Commitments[0].Equals(proof.Commitments[2]))
+	err = proof.VerifyForCurves(curves)
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(Proof)
+	err = deser.DeserializeForCurves(curves, ser)
+	require.NoError(t, err)
+	err = deser.VerifyForCurves(curves)
+	require.NoError(t, err)
+
+	commitments, err := VerifyStreamForCurves(curves, bytes.NewReader(ser))
+	require.NoError(t, err)
+	for c := range curves {
+		require.True(t, proof.Commitments[c].Equals(commitments[c]))
+	}
+
+	// the number of curves must match
+	err = deser.Deserialize(curves[0], curves[1], ser)
+	require.Error(t, err)
+	err = proof.Verify(curves[0], curves[1])
+	require.Error(t, err)
+
+	_, err = NewProofForCurves(curves[:1], x)
+	require.ErrorIs(t, err, errTooFewCurves)
+}
+
+func TestVerify_CommitmentMismatch(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := generateRandomBits(32)
+	require.NoError(t, err)
+	proof, err := NewProof(curveA, curveB, x, WithBits(32))
+	require.NoError(t, err)
+
+	// CommitmentA and CommitmentB must be the verified commitments
+	commitmentB := proof.CommitmentB
+	proof.CommitmentB = curveB.BasePoint()
+	err = proof.Verify(curveA, curveB, WithMinBits(32))
+	require.ErrorIs(t, err, errCommitmentMismatch)
+	proof.CommitmentB = nil
+	err = proof.Verify(curveA, curveB, WithMinBits(32))
+	require.ErrorIs(t, err, errCommitmentMismatch)
+
+	proof.CommitmentB = commitmentB
+	proof.CommitmentA = curveA.BasePoint()
+	err = proof.Verify(curveA, curveB, WithMinBits(32))
+	require.ErrorIs(t, err, errCommitmentMismatch)
+}
+
+func TestProveAndVerify_BIP340(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+
+	proof, err := NewProof(curveA, curveB, x, WithSignatureScheme(types.BIP340))
+	require.NoError(t, err)
+	require.Equal(t, types.BIP340, proof.signatures[0].scheme)
+	require.Equal(t, types.DefaultSignatureScheme, proof.signatures[1].scheme)
+	require.Equal(t, secp256k1.BIP340SignatureSize, len(proof.signatures[0].inner))
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	require.Equal(t, proofVersion1|signatureSchemesFlag, ser[0])
+	deser := new(Proof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	_, _, err = VerifyStream(curveA, curveB, bytes.NewReader(ser))
+	require.NoError(t, err)
+
+	// the signature must be verified with its own scheme
+	deser.signatures[0].scheme = types.DefaultSignatureScheme
+	err = deser.Verify(curveA, curveB)
+	require.Error(t, err)
+
+	// proofs using the default scheme are encoded as before
+	proof, err = NewProof(curveA, curveB, x)
+	require.NoError(t, err)
+	require.Equal(t, proofVersion1, proof.Serialize()[0])
+
+	_, err = NewProof(curveB, curveB, x, WithSignatureScheme(types.BIP340))
+	require.Error(t, err)
+	_, err = NewPublicKeyRangeProof(curveA, x, WithSignatureScheme(types.BIP340))
+	require.ErrorIs(t, err, errSignatureSchemes)
+}
+
+func TestProveAndVerify_MoneroSignature(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+
+	proof, err := NewProof(curveA, curveB, x,
+		WithSignatureScheme(types.BIP340),
+		WithSignatureScheme(types.Monero),
+	)
+	require.NoError(t, err)
+	require.Equal(t, types.BIP340, proof.signatures[0].scheme)
+	require.Equal(t, types.Monero, proof.signatures[1].scheme)
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(Proof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	// the signature on curve B is a Monero signature of keccak256(X_B) by X_B
+	h := sha3.NewLegacyKeccak256()
+	h.Write(proof.CommitmentB.Encode())
+	require.True(t, ed25519.VerifyMonero(proof.CommitmentB, h.Sum(nil), proof.signatures[1].inner))
+}
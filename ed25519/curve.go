@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
 
 	"github.com/athanorlabs/go-dleq/types"
 	"golang.org/x/crypto/sha3"
@@ -53,6 +54,20 @@ func (*CurveImpl) CompressedPointSize() int {
 	return 32
 }
 
+var _ types.Orderer = &CurveImpl{}
+
+// order is the order of the base point, 2^252 + 27742317777372353535851937790883648493.
+var order, _ = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+
+// Order returns the order of the curve's base point.
+func (*CurveImpl) Order() *big.Int {
+	return new(big.Int).Set(order)
+}
+
+func (*CurveImpl) ScalarSize() int {
+	return 32
+}
+
 func (*CurveImpl) DecodeToPoint(in []byte) (Point, error) {
 	cp := make([]byte, len(in))
 	copy(cp, in)
@@ -110,8 +125,14 @@ func (*CurveImpl) NewRandomScalar() Scalar {
 	}
 }
 
-func (*CurveImpl) ScalarFromBytes(b [32]byte) Scalar {
-	s, err := new(edwards25519.Scalar).SetCanonicalBytes(b[:])
+func (*CurveImpl) ScalarFromBytes(b []byte) Scalar {
+	if len(b) > 32 {
+		panic("scalar encoding must be at most 32 bytes")
+	}
+
+	var le [32]byte
+	copy(le[:], b)
+	s, err := new(edwards25519.Scalar).SetCanonicalBytes(le[:])
 	if err != nil {
 		panic(err)
 	}
@@ -124,11 +145,7 @@ func (*CurveImpl) ScalarFromBytes(b [32]byte) Scalar {
 func (c *CurveImpl) ScalarFromInt(in uint32) Scalar {
 	b := make([]byte, 4)
 	binary.LittleEndian.PutUint32(b[:], in)
-
-	bFull := [32]byte{}
-	copy(bFull[:4], b)
-
-	return c.ScalarFromBytes(bFull)
+	return c.ScalarFromBytes(b)
 }
 
 func (*CurveImpl) HashToScalar(in []byte) (Scalar, error) {
@@ -170,6 +187,38 @@ func (*CurveImpl) ScalarMul(s Scalar, p Point) Point {
 	}
 }
 
+var _ types.MultiScalarMuler = &CurveImpl{}
+
+// VarTimeMultiScalarMul returns the sum of scalars[i]*points[i].
+// It must only be used with public inputs.
+func (*CurveImpl) VarTimeMultiScalarMul(scalars []Scalar, points []Point) Point {
+	if len(scalars) != len(points) {
+		panic("number of scalars and points must match")
+	}
+
+	ss := make([]*edwards25519.Scalar, len(scalars))
+	for i, s := range scalars {
+		sc, ok := s.(*ScalarImpl)
+		if !ok {
+			panic("invalid scalar; type is not *ed25519.ScalarImpl")
+		}
+		ss[i] = sc.inner
+	}
+
+	pp := make([]*edwards25519.Point, len(points))
+	for i, p := range points {
+		pt, ok := p.(*PointImpl)
+		if !ok {
+			panic("invalid point; type is not *ed25519.PointImpl")
+		}
+		pp[i] = pt.inner
+	}
+
+	return &PointImpl{
+		inner: new(edwards25519.Point).VarTimeMultiScalarMult(ss, pp),
+	}
+}
+
 func (*CurveImpl) Sign(s Scalar, p Point) ([]byte, error) {
 	ss, ok := s.(*ScalarImpl)
 	if !ok {
@@ -235,6 +284,25 @@ func (*CurveImpl) Verify(pubkey, msgPoint Point, sig []byte) bool {
 	return res.Equal(R) == 1
 }
 
+// SignatureChallenge returns the challenge of an Ed25519 signature with the
+// nonce point R by the public key A, ie. SHA-512(R || A || msg) reduced
+// modulo the curve order, as in RFC 8032.
+func SignatureChallenge(R, A Point, msg []byte) Scalar {
+	h := sha512.New()
+	h.Write(R.Encode())
+	h.Write(A.Encode())
+	h.Write(msg)
+
+	ch, err := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	return &ScalarImpl{
+		inner: ch,
+	}
+}
+
 type ScalarImpl struct {
 	inner *edwards25519.Scalar
 }
@@ -353,13 +421,9 @@ func (p *PointImpl) Encode() []byte {
 	return p.inner.Bytes()
 }
 
+// IsZero returns whether the point is the identity.
 func (p *PointImpl) IsZero() bool {
-	var zero [32]byte
-	zp, err := new(edwards25519.Point).SetBytes(zero[:])
-	if err != nil {
-		panic(err)
-	}
-	return p.inner.Equal(zp) == 1
+	return p.inner.Equal(edwards25519.NewIdentityPoint()) == 1
 }
 
 func (p *PointImpl) Equals(other Point) bool {
@@ -0,0 +1,22 @@
+package ed25519
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointImpl_IsZero(t *testing.T) {
+	curve := NewCurve()
+	identity := curve.ScalarBaseMul(curve.ScalarFromInt(0))
+	require.True(t, identity.IsZero())
+	require.False(t, curve.BasePoint().IsZero())
+	require.True(t, curve.BasePoint().Sub(curve.BasePoint()).IsZero())
+
+	// the all-zero encoding is a point of order 4, not the identity
+	var zero [32]byte
+	p, err := curve.DecodeToPoint(zero[:])
+	require.NoError(t, err)
+	require.False(t, p.IsZero())
+	require.True(t, p.ScalarMul(curve.ScalarFromInt(4)).IsZero())
+}
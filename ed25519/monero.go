@@ -0,0 +1,161 @@
+package ed25519
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// MoneroSignatureSize is the length of a Monero signature.
+const MoneroSignatureSize = 64
+
+var _ types.SchemeSigner = &CurveImpl{}
+
+// MoneroHashToScalar returns Monero's hash_to_scalar of the input, ie. its
+// Keccak-256 hash reduced modulo the curve order.
+func MoneroHashToScalar(in []byte) Scalar {
+	return &ScalarImpl{
+		inner: moneroHashToScalar(in),
+	}
+}
+
+func moneroHashToScalar(in []byte) *edwards25519.Scalar {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(in)
+
+	var wide [64]byte
+	copy(wide[:], h.Sum(nil))
+	s, err := new(edwards25519.Scalar).SetUniformBytes(wide[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// moneroChallenge returns the challenge H_s(prefixHash || pub || comm) of
+// a Monero signature.
+func moneroChallenge(prefixHash []byte, pub, comm *edwards25519.Point) *edwards25519.Scalar {
+	buf := make([]byte, 0, 96)
+	buf = append(buf, prefixHash...)
+	buf = append(buf, pub.Bytes()...)
+	buf = append(buf, comm.Bytes()...)
+	return moneroHashToScalar(buf)
+}
+
+// SignMonero returns the signature of the 32-byte prefixHash by the secret
+// key s, as generated by Monero's crypto::generate_signature.
+// The signature is c || r, where c = H_s(prefixHash || s*G || k*G) and
+// r = k - c*s for a random nonce k.
+func SignMonero(s Scalar, prefixHash []byte) ([]byte, error) {
+	ss, ok := s.(*ScalarImpl)
+	if !ok {
+		panic("invalid scalar; type is not *ed25519.ScalarImpl")
+	}
+
+	if len(prefixHash) != 32 {
+		return nil, errors.New("prefix hash must be 32 bytes")
+	}
+
+	pub := new(edwards25519.Point).ScalarBaseMult(ss.inner)
+	zero := edwards25519.NewScalar()
+	for {
+		var seed [64]byte
+		_, err := rand.Read(seed[:])
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := new(edwards25519.Scalar).SetUniformBytes(seed[:])
+		if err != nil {
+			return nil, err
+		}
+
+		comm := new(edwards25519.Point).ScalarBaseMult(k)
+		c := moneroChallenge(prefixHash, pub, comm)
+		if c.Equal(zero) == 1 {
+			continue
+		}
+
+		// r = k - c*s
+		r := new(edwards25519.Scalar).Subtract(k, new(edwards25519.Scalar).Multiply(c, ss.inner))
+		if r.Equal(zero) == 1 {
+			continue
+		}
+
+		return append(c.Bytes(), r.Bytes()...), nil
+	}
+}
+
+// VerifyMonero verifies a signature of the 32-byte prefixHash by pubkey, as
+// Monero's crypto::check_signature does.
+func VerifyMonero(pubkey Point, prefixHash, sig []byte) bool {
+	pp, ok := pubkey.(*PointImpl)
+	if !ok {
+		panic("invalid point; type is not *ed25519.PointImpl")
+	}
+
+	if len(prefixHash) != 32 || len(sig) != MoneroSignatureSize {
+		return false
+	}
+
+	c, err := new(edwards25519.Scalar).SetCanonicalBytes(sig[:32])
+	if err != nil {
+		return false
+	}
+
+	r, err := new(edwards25519.Scalar).SetCanonicalBytes(sig[32:])
+	if err != nil {
+		return false
+	}
+
+	// comm = c*P + r*G
+	comm := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(c, pp.inner, r)
+	if comm.Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return false
+	}
+
+	return moneroChallenge(prefixHash, pp.inner, comm).Equal(c) == 1
+}
+
+// SupportsSignatureScheme returns whether the curve supports the given scheme,
+// which are the default scheme and Monero signatures.
+func (*CurveImpl) SupportsSignatureScheme(scheme types.SignatureScheme) bool {
+	return scheme == types.DefaultSignatureScheme || scheme == types.Monero
+}
+
+// SignWithScheme signs the encoded point `p` with the private key `s` using the
+// given signature scheme. The prefix hash of Monero signatures is the
+// Keccak-256 hash of p.
+func (c *CurveImpl) SignWithScheme(scheme types.SignatureScheme, s Scalar, p Point) ([]byte, error) {
+	switch scheme {
+	case types.DefaultSignatureScheme:
+		return c.Sign(s, p)
+	case types.Monero:
+		return SignMonero(s, moneroPrefixHash(p))
+	default:
+		return nil, errors.New("unsupported signature scheme")
+	}
+}
+
+// VerifyWithScheme verifies a signature generated by SignWithScheme.
+func (c *CurveImpl) VerifyWithScheme(scheme types.SignatureScheme, pubkey, msgPoint Point, sig []byte) bool {
+	switch scheme {
+	case types.DefaultSignatureScheme:
+		return c.Verify(pubkey, msgPoint, sig)
+	case types.Monero:
+		return VerifyMonero(pubkey, moneroPrefixHash(msgPoint), sig)
+	default:
+		return false
+	}
+}
+
+func moneroPrefixHash(p Point) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(p.Encode())
+	return h.Sum(nil)
+}
@@ -0,0 +1,81 @@
+package ed25519
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// TestMoneroHashToScalar uses the hash_to_scalar vectors of Monero's
+// tests/crypto/tests.txt.
+func TestMoneroHashToScalar(t *testing.T) {
+	vectors := []struct {
+		in, out string
+	}{
+		{"59d28aeade98016722948bf596af0b7deb5dd641f1aa2a906bd4e1", "7d0b25809fc4032a81dd5b0f721a2b21f7f68157c834374f580876f5d91f7409"},
+		{"60d9a4b96951481ab458", "b0955682b297dbcae4a5c1b6f21addb211d6180632b538472045b5d592c38109"},
+		{"7d535b4896ddc350a5fdff", "7bb1a59783be93ada537801f31ef52b0d2ea135a084c47cbad9a7c6b0d2c990f"},
+		{"14b5ff33", "709162ee2552c852ba62d406efd369d65851777152c9df4b61a2c4e19190c408"},
+		{"383b76f631652889a182f308b18ddc4e405ba9a9cba5c01b", "36ddbd71a4c19db5ea7022571a52f5a9abe33fc00aafd24b562fb75b7fc0360b"},
+		{"3a170545e462830baf", "c381ea27500b61d29e9ad27add0168053cc1a5b7fc58b6960f67c147324acb03"},
+		{"190757c55bc7", "357f141395a76e2fd5003045b75f3216294eab0524eda1ed16cbe558145a2403"},
+		{"e1dec4027ccb5bf7d273163b316a86", "b365e89545402d3e7d649987127980ec8339af2e3067ff942e305a9ac0b7390d"},
+	}
+
+	for _, v := range vectors {
+		in, err := hex.DecodeString(v.in)
+		require.NoError(t, err)
+		require.Equal(t, v.out, hex.EncodeToString(MoneroHashToScalar(in).Encode()))
+	}
+}
+
+func TestMoneroSignature(t *testing.T) {
+	curve := NewCurve()
+	s := curve.NewRandomScalar()
+	P := curve.ScalarBaseMul(s)
+	prefixHash := make([]byte, 32)
+	prefixHash[0] = 1
+
+	sig, err := SignMonero(s, prefixHash)
+	require.NoError(t, err)
+	require.Equal(t, MoneroSignatureSize, len(sig))
+	require.True(t, VerifyMonero(P, prefixHash, sig))
+
+	// the challenge is H_s(prefixHash || P || c*P + r*G)
+	c, err := curve.DecodeToScalar(sig[:32])
+	require.NoError(t, err)
+	r, err := curve.DecodeToScalar(sig[32:])
+	require.NoError(t, err)
+	comm := P.ScalarMul(c).Add(curve.ScalarBaseMul(r))
+	buf := append(append(append([]byte{}, prefixHash...), P.Encode()...), comm.Encode()...)
+	require.True(t, MoneroHashToScalar(buf).Eq(c))
+
+	require.False(t, VerifyMonero(curve.BasePoint(), prefixHash, sig))
+	prefixHash[0] = 2
+	require.False(t, VerifyMonero(P, prefixHash, sig))
+	require.False(t, VerifyMonero(P, prefixHash, sig[:63]))
+
+	_, err = SignMonero(s, prefixHash[:31])
+	require.Error(t, err)
+}
+
+func TestMoneroSignature_WithScheme(t *testing.T) {
+	curve := NewCurve().(*CurveImpl)
+	s := curve.NewRandomScalar()
+	P := curve.ScalarBaseMul(s)
+
+	for _, scheme := range []types.SignatureScheme{types.DefaultSignatureScheme, types.Monero} {
+		require.True(t, curve.SupportsSignatureScheme(scheme))
+		sig, err := curve.SignWithScheme(scheme, s, P)
+		require.NoError(t, err)
+		require.True(t, curve.VerifyWithScheme(scheme, P, P, sig))
+		require.False(t, curve.VerifyWithScheme(scheme, P, curve.BasePoint(), sig))
+	}
+
+	require.False(t, curve.SupportsSignatureScheme(types.BIP340))
+	_, err := curve.SignWithScheme(types.BIP340, s, P)
+	require.Error(t, err)
+}
@@ -0,0 +1,383 @@
+package dleq
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+const encryptionDomain = "go-dleq/encryption"
+
+var errInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Ciphertext is a verifiable encryption of a witness to a recipient's public
+// key Y = y*G. Each digit d_i of the witness is encrypted with ElGamal as
+// A_i = r_i*G, B_i = d_i*G + r_i*Y, where the B_i are digit commitments as in
+// a Proof, with Y in place of the alternate base point. The randomness r_i is
+// chosen such that sum(r_i * radix^i) is zero, so the B_i sum to x*G.
+//
+// Each digit has a ring signature proving that A_i and B_i - j*G have the same
+// discrete logarithm with respect to G and Y for some digit j, which shows
+// that the digit decrypts to j. So the ciphertext decrypts to the discrete
+// logarithm of x*G, which can be CommitmentA or CommitmentB of a Proof.
+type Ciphertext struct {
+	radix  uint64
+	bits   uint64
+	digits []encryptedDigit
+}
+
+// encryptedDigit is the ElGamal encryption of a single digit, along with its
+// ring signature.
+type encryptedDigit struct {
+	a, b Point
+	// e is the challenge of the first ring member.
+	e Scalar
+	// s contains the response of each ring member.
+	s []Scalar
+}
+
+// Bits returns the bit length n of the encrypted witness, ie. the ciphertext
+// decrypts to a value x < 2^n.
+func (c *Ciphertext) Bits() uint64 {
+	return c.bits
+}
+
+// Encrypt encrypts the witness x to the recipient's public key on the given
+// curve, such that anyone can verify that the ciphertext decrypts to the
+// discrete logarithm of x*G with VerifyCiphertext.
+// The witness x must be in little-endian and smaller than the order of the
+// curve, or smaller than 2^n if a bit length n is set with WithBits.
+// Only WithRadix and WithBits apply to the encryption, which must have at
+// least two digits.
+func Encrypt(curve Curve, recipient Point, x []byte, opts ...Option) (*Ciphertext, error) {
+	if recipient == nil || recipient.IsZero() {
+		return nil, errors.New("recipient key must not be zero")
+	}
+
+	o, err := newOptions(opts, curve.BitSize())
+	if err != nil {
+		return nil, err
+	}
+	if len(o.signatureSchemes) != 0 {
+		return nil, errSignatureSchemes
+	}
+
+	err = checkEncryptionDigits(o.bits, o.radix)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err = normalizeWitness(x, o.bits)
+	if err != nil {
+		return nil, err
+	}
+
+	// the blinders sum to zero, so the digit commitments sum to x*G
+	commitments, err := generateCommitmentsWithBase(curve, recipient, x, o.bits, o.radix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	digits := make([]encryptedDigit, len(commitments))
+	for i, c := range commitments {
+		digits[i] = encryptedDigit{
+			a: curve.ScalarBaseMul(c.blinder),
+			b: c.commitment,
+		}
+
+		digit := getDigit(x, o.bits, o.radix, uint64(i))
+		ringSize := digitRingSize(o.bits, o.radix, uint64(i))
+		err = digits[i].sign(curve, recipient, digit, ringSize, c.blinder)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Ciphertext{
+		radix:  o.radix,
+		bits:   o.bits,
+		digits: digits,
+	}, nil
+}
+
+// checkEncryptionDigits checks that a witness with the given bit length has
+// at least two digits, since the randomness of a single digit would be zero.
+func checkEncryptionDigits(bits, radix uint64) error {
+	if numDigits(bits, radix) < 2 {
+		return fmt.Errorf("bit length must be greater than %d for radix %d", log2(radix), radix)
+	}
+
+	return nil
+}
+
+// sign generates the ring signature over the ring members (A, B - j*G) for j
+// in [0, ringSize), where the digit encrypts the given digit with randomness r.
+func (d *encryptedDigit) sign(curve Curve, recipient Point, digit, ringSize uint64, r Scalar) error {
+	if digit >= ringSize {
+		return fmt.Errorf("digit must be less than %d", ringSize)
+	}
+
+	e := make([]Scalar, ringSize)
+	d.s = make([]Scalar, ringSize)
+
+	// start the ring at the member after the one we know the secret for
+	k := curve.NewRandomScalar()
+	next := (digit + 1) % ringSize
+	var err error
+	e[next], err = d.challenge(curve, recipient, curve.ScalarBaseMul(k), recipient.ScalarMul(k))
+	if err != nil {
+		return err
+	}
+
+	for i := next; i != digit; i = (i + 1) % ringSize {
+		d.s[i] = curve.NewRandomScalar()
+		RG, RY := d.commitments(curve, recipient, i, d.s[i], e[i])
+		e[(i+1)%ringSize], err = d.challenge(curve, recipient, RG, RY)
+		if err != nil {
+			return err
+		}
+	}
+
+	// close the ring
+	d.s[digit] = k.Add(e[digit].Mul(r))
+	d.e = e[0]
+	return nil
+}
+
+// commitments returns the nonce commitments of the i'th ring member, ie.
+// s*G - e*A and s*Y - e*(B - i*G).
+func (d *encryptedDigit) commitments(curve Curve, recipient Point, i uint64, s, e Scalar) (Point, Point) {
+	RG := curve.ScalarBaseMul(s).Sub(d.a.ScalarMul(e))
+	RY := recipient.ScalarMul(s).Sub(ringMember(curve, d.b, i).ScalarMul(e))
+	return RG, RY
+}
+
+// challenge returns the challenge of the next ring member.
+func (d *encryptedDigit) challenge(curve Curve, recipient, RG, RY Point) (Scalar, error) {
+	preimage, err := encodeElements(recipient, d.a, d.b, RG, RY)
+	if err != nil {
+		return nil, err
+	}
+
+	return curve.HashToScalar(append([]byte(encryptionDomain), preimage...))
+}
+
+// verify verifies the ring signature of the digit.
+func (d *encryptedDigit) verify(curve Curve, recipient Point, ringSize uint64) error {
+	if d.e == nil || uint64(len(d.s)) != ringSize {
+		return errors.New("invalid ring signature size")
+	}
+
+	e := d.e
+	for i := uint64(0); i < ringSize; i++ {
+		RG, RY := d.commitments(curve, recipient, i, d.s[i], e)
+
+		var err error
+		e, err = d.challenge(curve, recipient, RG, RY)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !e.Eq(d.e) {
+		return errInvalidCiphertext
+	}
+
+	return nil
+}
+
+// VerifyCiphertext verifies that the ciphertext decrypts, with the secret key
+// of the recipient's public key, to the discrete logarithm of commitment.
+// By default, the ciphertext must be for a witness of the full bit size of
+// the curve; see WithMinBits.
+func VerifyCiphertext(curve Curve, recipient, commitment Point, ct *Ciphertext, opts ...VerifyOption) error {
+	if recipient == nil || recipient.IsZero() || commitment == nil {
+		return errInvalidCiphertext
+	}
+
+	err := ct.checkParameters(curve, opts)
+	if err != nil {
+		return err
+	}
+
+	aSum := newCommitmentSum(curve, ct.radix)
+	bSum := newCommitmentSum(curve, ct.radix)
+	for _, d := range ct.digits {
+		aSum.add(d.a)
+		bSum.add(d.b)
+	}
+
+	// sum(r_i * radix^i) must be zero, so that the digits decrypt to the
+	// discrete logarithm of the sum of the B_i
+	if !aSum.sum.IsZero() {
+		return errInvalidCiphertext
+	}
+
+	if !bSum.equals(commitment) {
+		return errCommitmentsSum
+	}
+
+	for i := range ct.digits {
+		err = ct.digits[i].verify(curve, recipient, digitRingSize(ct.bits, ct.radix, uint64(i)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkParameters checks the parameters of the ciphertext are valid and
+// accepted by the verifier.
+func (c *Ciphertext) checkParameters(curve Curve, opts []VerifyOption) error {
+	err := checkRadix(c.radix)
+	if err != nil {
+		return err
+	}
+
+	maxBits := curve.BitSize()
+	err = newVerifyOptions(opts, maxBits).checkBits(c.bits, maxBits)
+	if err != nil {
+		return err
+	}
+
+	err = checkEncryptionDigits(c.bits, c.radix)
+	if err != nil {
+		return err
+	}
+
+	n := numDigits(c.bits, c.radix)
+	if uint64(len(c.digits)) != n {
+		return fmt.Errorf("invalid number of digits: expected %d, got %d", n, len(c.digits))
+	}
+
+	return nil
+}
+
+// Decrypt decrypts the ciphertext with the recipient's secret key y, returning
+// the witness in little-endian. The ciphertext should have been verified with
+// VerifyCiphertext, otherwise it may not decrypt to the expected witness.
+func Decrypt(curve Curve, y Scalar, ct *Ciphertext) ([]byte, error) {
+	if y == nil || y.IsZero() {
+		return nil, errors.New("secret key must not be zero")
+	}
+
+	err := checkRadix(ct.radix)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(ct.digits)) != numDigits(ct.bits, ct.radix) {
+		return nil, errInvalidCiphertext
+	}
+
+	x := make([]byte, (ct.bits+7)/8)
+	w := log2(ct.radix)
+	for i, d := range ct.digits {
+		// d_i*G = B_i - y*A_i
+		dG := d.b.Sub(d.a.ScalarMul(y))
+		digit, err := decryptDigit(curve, dG, digitRingSize(ct.bits, ct.radix, uint64(i)))
+		if err != nil {
+			return nil, err
+		}
+
+		for j := uint64(0); j < digitWidth(ct.bits, ct.radix, uint64(i)); j++ {
+			bit := uint64(i)*w + j
+			x[bit/8] |= byte((digit>>j)&1) << (bit % 8)
+		}
+	}
+
+	return x, nil
+}
+
+// decryptDigit returns the digit j in [0, ringSize) such that dG = j*G.
+func decryptDigit(curve Curve, dG Point, ringSize uint64) (uint64, error) {
+	if dG.IsZero() {
+		return 0, nil
+	}
+
+	G := curve.BasePoint()
+	jG := G.Copy()
+	for j := uint64(1); j < ringSize; j++ {
+		if jG.Equals(dG) {
+			return j, nil
+		}
+
+		jG = jG.Add(G)
+	}
+
+	return 0, errors.New("failed to decrypt digit")
+}
+
+// Serialize encodes the ciphertext.
+//
+// The encoding is: radix (1 byte) || witness bit length (2 bytes,
+// little-endian) || encrypted digits, where each digit is A || B ||
+// challenge || responses.
+func (c *Ciphertext) Serialize() []byte {
+	b := []byte{byte(c.radix), 0, 0}
+	binary.LittleEndian.PutUint16(b[1:], uint16(c.bits))
+	for _, d := range c.digits {
+		b = append(b, d.a.Encode()...)
+		b = append(b, d.b.Encode()...)
+		b = append(b, d.e.Encode()...)
+		for _, s := range d.s {
+			b = append(b, s.Encode()...)
+		}
+	}
+
+	return b
+}
+
+// Deserialize decodes the ciphertext for the given curve.
+func (c *Ciphertext) Deserialize(curve types.Curve, in []byte) error {
+	d := newDecoder(in)
+	b, err := d.next(3)
+	if err != nil {
+		return err
+	}
+
+	radix, bits := uint64(b[0]), uint64(binary.LittleEndian.Uint16(b[1:]))
+	err = checkRadix(radix)
+	if err != nil {
+		return err
+	}
+
+	// check the bit length before allocating the digits
+	if bits == 0 || bits > curve.BitSize() {
+		return fmt.Errorf("bit length must be between 1 and %d, got %d", curve.BitSize(), bits)
+	}
+
+	digits := make([]encryptedDigit, numDigits(bits, radix))
+	for i := range digits {
+		digits[i].a, err = d.readPoint(curve)
+		if err != nil {
+			return err
+		}
+
+		digits[i].b, err = d.readPoint(curve)
+		if err != nil {
+			return err
+		}
+
+		digits[i].e, err = d.readScalar(curve)
+		if err != nil {
+			return err
+		}
+
+		digits[i].s = make([]Scalar, digitRingSize(bits, radix, uint64(i)))
+		for j := range digits[i].s {
+			digits[i].s[j], err = d.readScalar(curve)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	c.radix = radix
+	c.bits = bits
+	c.digits = digits
+	return nil
+}
@@ -0,0 +1,127 @@
+package dleq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+)
+
+func TestEncrypt(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	proof, err := NewProof(curveA, curveB, x)
+	require.NoError(t, err)
+
+	for c, curve := range []Curve{curveA, curveB} {
+		y := curve.NewRandomScalar()
+		Y := curve.ScalarBaseMul(y)
+
+		ct, err := Encrypt(curve, Y, x, WithRadix(16))
+		require.NoError(t, err)
+		err = VerifyCiphertext(curve, Y, proof.Commitments[c], ct)
+		require.NoError(t, err)
+
+		// the ciphertext is for a different commitment and recipient
+		err = VerifyCiphertext(curve, Y, curve.ScalarBaseMul(y), ct)
+		require.Error(t, err)
+		err = VerifyCiphertext(curve, proof.Commitments[c], proof.Commitments[c], ct)
+		require.Error(t, err)
+
+		decrypted, err := Decrypt(curve, y, ct)
+		require.NoError(t, err)
+		require.Equal(t, x, decrypted[:len(x)])
+
+		// decrypting with the wrong key fails
+		_, err = Decrypt(curve, curve.NewRandomScalar(), ct)
+		require.Error(t, err)
+	}
+}
+
+func TestEncrypt_Serialize(t *testing.T) {
+	curve := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curve)
+	require.NoError(t, err)
+	X := curve.ScalarBaseMul(curve.ScalarFromBytes(x))
+	y := curve.NewRandomScalar()
+	Y := curve.ScalarBaseMul(y)
+
+	ct, err := Encrypt(curve, Y, x, WithRadix(8))
+	require.NoError(t, err)
+
+	ser := ct.Serialize()
+	deser := new(Ciphertext)
+	err = deser.Deserialize(curve, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = VerifyCiphertext(curve, Y, X, deser)
+	require.NoError(t, err)
+
+	decrypted, err := Decrypt(curve, y, deser)
+	require.NoError(t, err)
+	require.Equal(t, x, decrypted)
+
+	err = deser.Deserialize(curve, ser[:len(ser)-1])
+	require.Error(t, err)
+
+	// tamper with a response
+	ser[len(ser)-2] ^= 1
+	err = deser.Deserialize(curve, ser)
+	require.NoError(t, err)
+	err = VerifyCiphertext(curve, Y, X, deser)
+	require.Error(t, err)
+}
+
+func TestEncrypt_Bits(t *testing.T) {
+	curve := secp256k1.NewCurve()
+	y := curve.NewRandomScalar()
+	Y := curve.ScalarBaseMul(y)
+	x := []byte{0xab, 0x01}
+	X := curve.ScalarBaseMul(curve.ScalarFromBytes(x))
+
+	ct, err := Encrypt(curve, Y, x, WithBits(9))
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), ct.Bits())
+	err = VerifyCiphertext(curve, Y, X, ct)
+	require.Error(t, err)
+	err = VerifyCiphertext(curve, Y, X, ct, WithMinBits(9))
+	require.NoError(t, err)
+
+	decrypted, err := Decrypt(curve, y, ct)
+	require.NoError(t, err)
+	require.Equal(t, x, decrypted)
+
+	// the witness must be smaller than 2^bits
+	_, err = Encrypt(curve, Y, x, WithBits(8))
+	require.Error(t, err)
+
+	// a single digit can't be encrypted
+	_, err = Encrypt(curve, Y, []byte{1}, WithBits(2), WithRadix(4))
+	require.Error(t, err)
+
+	_, err = Encrypt(curve, Y, x, WithBits(9), WithSignatureScheme(1))
+	require.ErrorIs(t, err, errSignatureSchemes)
+}
+
+func TestVerifyCiphertext_WrongWitness(t *testing.T) {
+	curve := secp256k1.NewCurve()
+	Y := curve.ScalarBaseMul(curve.NewRandomScalar())
+	x := []byte{0xab, 0x01}
+	other := []byte{0xab, 0x00}
+
+	ct, err := Encrypt(curve, Y, x, WithBits(9))
+	require.NoError(t, err)
+	err = VerifyCiphertext(curve, Y, curve.ScalarBaseMul(curve.ScalarFromBytes(other)), ct, WithMinBits(9))
+	require.Error(t, err)
+
+	// swapping the encrypted digits of two ciphertexts breaks the sum of the randomness
+	ct2, err := Encrypt(curve, Y, other, WithBits(9))
+	require.NoError(t, err)
+	ct2.digits[0] = ct.digits[0]
+	err = VerifyCiphertext(curve, Y, curve.ScalarBaseMul(curve.ScalarFromBytes(other)), ct2, WithMinBits(9))
+	require.Error(t, err)
+}
@@ -0,0 +1,285 @@
+package dleq
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/athanorlabs/go-dleq/internal/fullrange"
+	"github.com/athanorlabs/go-dleq/internal/littleendian"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// FullRangeProof proves that commitments on two curves have the same discrete
+// logarithm x, for any x smaller than the order l_B of curve B, such as any
+// ed25519 scalar. Proofs generated with NewProof are limited to witnesses of
+// the minimum of the curves' BitSize, ie. x < 2^252 for ed25519.
+//
+// Let b be the bit length of l_B. The proof consists of:
+//   - a proof that the commitments on both curves are to the same integer
+//     x < 2^b, which may be larger than l_B, in which case the commitment on
+//     curve B is to x mod l_B, and
+//   - a range proof on curve A that y = x + 2^b - l_B < 2^b, ie. that x < l_B.
+//
+// Curve A's BitSize must be greater than b, so that neither x nor y wrap
+// around the order of curve A. Then the range proof shows that x < l_B as an
+// integer, so the commitment on curve B is to x itself, the same integer as on
+// curve A. Unlike disclosing whether x >= 2^(b-1), which would reveal that x
+// lies in a much smaller interval, the proof reveals nothing about x.
+//
+// Curve B must implement types.Orderer.
+type FullRangeProof struct {
+	CommitmentA, CommitmentB Point
+	digitProofs
+	signatures []signature
+	rangeProof RangeProof
+}
+
+// fullRangeParams returns the order of curve B, its bit length b, and
+// 2^b - l_B.
+func fullRangeParams(curveA, curveB Curve) (*big.Int, uint64, *big.Int, error) {
+	orderer, ok := curveB.(types.Orderer)
+	if !ok {
+		return nil, 0, nil, errors.New("curve B must implement types.Orderer")
+	}
+
+	order := orderer.Order()
+	bits := uint64(order.BitLen())
+	if curveA.BitSize() <= bits {
+		return nil, 0, nil, fmt.Errorf("curve A must have a bit size greater than %d", bits)
+	}
+
+	offset := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	offset.Sub(offset, order)
+	return order, bits, offset, nil
+}
+
+var errFullRangeOptions = fullrange.ErrOptions
+
+func init() {
+	fullrange.CheckOptions = func(curveA, curveB types.Curve, opts interface{}) error {
+		_, bits, _, err := fullRangeParams(curveA, curveB)
+		if err != nil {
+			return err
+		}
+
+		_, err = fullRangeOptions(opts.([]Option), bits)
+		return err
+	}
+}
+
+// fullRangeOptions applies the given options for a full range proof of the
+// given bit length, of which only WithRadix and WithCompactEncoding can be set.
+func fullRangeOptions(opts []Option, bits uint64) (*options, error) {
+	o := applyOptions(opts, bits)
+	if o.bits != bits || len(o.signatureSchemes) != 0 {
+		return nil, errFullRangeOptions
+	}
+
+	err := checkRadix(o.radix)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// NewFullRangeProof returns a new proof for the given secret, which must be
+// in little-endian and smaller than the order of curve B.
+// Only WithRadix and WithCompactEncoding can be set.
+func NewFullRangeProof(curveA, curveB Curve, x []byte, opts ...Option) (*FullRangeProof, error) {
+	order, bits, offset, err := fullRangeParams(curveA, curveB)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := fullRangeOptions(opts, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err = normalizeWitness(x, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	xInt := littleendian.Int(x)
+	if xInt.Cmp(order) >= 0 {
+		return nil, errors.New("secret must be smaller than the order of curve B")
+	}
+
+	curves := []Curve{curveA, curveB}
+	secrets := make([]Scalar, len(curves))
+	commitments := make([]Point, len(curves))
+	for c, curve := range curves {
+		secrets[c] = scalarFromWitness(curve, x)
+		commitments[c] = curve.ScalarBaseMul(secrets[c])
+	}
+
+	digits, err := newDigitProofs(curves, x, o, nil, commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := make([]signature, len(curves))
+	for c, curve := range curves {
+		sig, err := curve.Sign(secrets[c], commitments[c])
+		if err != nil {
+			return nil, err
+		}
+
+		signatures[c] = signature{
+			inner: sig,
+		}
+	}
+
+	y := littleendian.Bytes(new(big.Int).Add(xInt, offset), len(x))
+	rangeProof, err := NewPublicKeyRangeProof(curveA, y, append(opts, WithBits(bits))...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FullRangeProof{
+		CommitmentA: commitments[0],
+		CommitmentB: commitments[1],
+		digitProofs: *digits,
+		signatures:  signatures,
+		rangeProof:  *rangeProof,
+	}, nil
+}
+
+// Verify verifies the proof is valid against the given curves.
+func (p *FullRangeProof) Verify(curveA, curveB Curve) error {
+	_, bits, offset, err := fullRangeParams(curveA, curveB)
+	if err != nil {
+		return err
+	}
+
+	if p.CommitmentA == nil || p.CommitmentB == nil || len(p.signatures) != 2 {
+		return errInvalidNumCommitments
+	}
+
+	err = p.digitProofs.checkParametersWithBits(bits)
+	if err != nil {
+		return err
+	}
+
+	if p.rangeProof.signature == nil {
+		return errors.New("range proof is not for a public key")
+	}
+
+	err = p.rangeProof.checkParametersWithBits(bits)
+	if err != nil {
+		return err
+	}
+
+	curves := []Curve{curveA, curveB}
+	commitments := []Point{p.CommitmentA, p.CommitmentB}
+	err = p.digitProofs.verify(curves, commitments)
+	if err != nil {
+		return err
+	}
+
+	err = verifySignatures(curves, commitments, p.signatures)
+	if err != nil {
+		return err
+	}
+
+	// Y = X_A + (2^b - l_B)*G_A
+	offsetScalar := scalarFromWitness(curveA, littleendian.Bytes(offset, curveA.ScalarSize()))
+	rangeProof := p.rangeProof
+	rangeProof.Commitment = p.CommitmentA.Add(curveA.ScalarBaseMul(offsetScalar))
+	err = rangeProof.VerifyPublicKey(curveA, WithMinBits(bits))
+	if err != nil {
+		return fmt.Errorf("failed to verify range proof: %w", err)
+	}
+
+	return nil
+}
+
+// checkParametersWithBits checks the version and radix of the proof, and that
+// its bit length is exactly the given length.
+func (p *digitProofs) checkParametersWithBits(bits uint64) error {
+	err := checkVersion(p.version)
+	if err != nil {
+		return err
+	}
+
+	err = checkRadix(p.radix)
+	if err != nil {
+		return err
+	}
+
+	if p.bits != bits {
+		return fmt.Errorf("bit length must be %d, got %d", bits, p.bits)
+	}
+
+	return nil
+}
+
+// Serialize encodes the proof.
+//
+// The encoding is the encoding of a Proof, with a bit length of the bit length
+// of curve B's order, followed by the encoding of the range proof on curve A.
+// The commitment of the range proof is omitted, as the verifier derives it.
+func (p *FullRangeProof) Serialize() []byte {
+	b := p.digitProofs.encode([]Point{p.CommitmentA, p.CommitmentB})
+	for _, sig := range p.signatures {
+		b = append(b, byte(len(sig.inner)))
+		b = append(b, sig.inner...)
+	}
+
+	b = append(b, p.rangeProof.encodeHeader(1)...)
+	b = append(b, p.rangeProof.encodeProofs()...)
+	b = append(b, byte(len(p.rangeProof.signature.inner)))
+	return append(b, p.rangeProof.signature.inner...)
+}
+
+// Deserialize decodes the proof for the given curves.
+func (p *FullRangeProof) Deserialize(curveA, curveB types.Curve, in []byte) error {
+	_, bits, offset, err := fullRangeParams(curveA, curveB)
+	if err != nil {
+		return err
+	}
+
+	curves := []types.Curve{curveA, curveB}
+	d := newDecoder(in)
+	_, commitments, err := p.digitProofs.decode(d, curves, bits, len(in))
+	if err != nil {
+		return err
+	}
+
+	signatures, err := d.readSignatures(len(curves))
+	if err != nil {
+		return err
+	}
+
+	h, err := d.readHeader(1)
+	if err != nil {
+		return err
+	}
+	if h.bits != bits {
+		return fmt.Errorf("bit length must be %d, got %d", bits, h.bits)
+	}
+
+	// the range proof's commitment is needed to decode compact proofs
+	offsetScalar := scalarFromWitness(curveA, littleendian.Bytes(offset, curveA.ScalarSize()))
+	rangeCommitment := commitments[0].Add(curveA.ScalarBaseMul(offsetScalar))
+	var rangeProof RangeProof
+	err = rangeProof.decodeProofs(d, curves[:1], h, []types.Point{rangeCommitment})
+	if err != nil {
+		return err
+	}
+
+	sig, err := d.readSignature()
+	if err != nil {
+		return err
+	}
+
+	rangeProof.Commitment = rangeCommitment
+	rangeProof.signature = &sig
+	p.CommitmentA, p.CommitmentB = commitments[0], commitments[1]
+	p.signatures = signatures
+	p.rangeProof = rangeProof
+	return nil
+}
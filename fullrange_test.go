@@ -0,0 +1,73 @@
+package dleq
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/internal/littleendian"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+func TestFullRangeProof(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	order := curveB.(types.Orderer).Order()
+
+	// the largest ed25519 scalar, which is over 252 bits
+	x := littleendian.Bytes(new(big.Int).Sub(order, big.NewInt(1)), 32)
+	_, err := NewProof(curveA, curveB, x)
+	require.Error(t, err)
+
+	proof, err := NewFullRangeProof(curveA, curveB, x, WithRadix(4))
+	require.NoError(t, err)
+	require.True(t, curveB.ScalarBaseMul(curveB.ScalarFromBytes(x)).Equals(proof.CommitmentB))
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(FullRangeProof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	deser.CommitmentA = deser.CommitmentA.Add(curveA.BasePoint())
+	err = deser.Verify(curveA, curveB)
+	require.Error(t, err)
+
+	// the secret must be smaller than the order of curve B
+	x = littleendian.Bytes(order, 32)
+	_, err = NewFullRangeProof(curveA, curveB, x)
+	require.Error(t, err)
+
+	// curve A must be larger than curve B's order
+	_, err = NewFullRangeProof(curveB, curveB, x)
+	require.Error(t, err)
+}
+
+func TestFullRangeProof_Compact(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+
+	proof, err := NewFullRangeProof(curveA, curveB, x, WithRadix(8), WithCompactEncoding())
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(FullRangeProof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	_, err = NewFullRangeProof(curveA, curveB, x, WithBits(252))
+	require.ErrorIs(t, err, errFullRangeOptions)
+	_, err = NewFullRangeProof(curveA, curveB, x, WithBits(256))
+	require.ErrorIs(t, err, errFullRangeOptions)
+}
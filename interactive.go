@@ -0,0 +1,489 @@
+package dleq
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+var (
+	errAlreadyCommitted  = errors.New("prover has already committed")
+	errNotCommitted      = errors.New("prover has not committed")
+	errAlreadyResponded  = errors.New("prover has already responded")
+	errNoChallenge       = errors.New("verifier has not issued a challenge")
+	errInvalidChallenge  = fmt.Errorf("challenge must be %d bytes for each digit", seedLen)
+	errInteractiveVerify = errors.New("invalid response")
+)
+
+// Prover is the prover of the interactive version of the proof, which is a
+// three-move protocol: the prover sends a commitment (Commit), the verifier
+// replies with a random challenge (Verifier.Challenge), and the prover sends
+// its response (Respond), which the verifier checks (Verifier.Verify).
+//
+// The protocol is the ring signatures of a compact Proof, split at the
+// challenge which closes each ring. In a Proof, each ring member's challenge
+// is derived by hashing the previous member's R, and the first member's
+// challenge by hashing the last member's R. Here, the prover instead commits to
+// the last member's R of each digit, and the verifier's challenge contains a
+// random 32-byte seed for each digit, which the first member's challenges are
+// derived from; the links between the other members are still hashed. The
+// verifier checks that completing each ring from its seed leads back to the
+// committed R. Knowledge of the witness is proven by the same signatures as a
+// Proof, which are sent with the commitment.
+//
+// Hashing each digit's commitments and committed R into its seed, as the
+// verifier's challenge, gives exactly the ring signatures of a compact Proof.
+//
+// A Prover must only be used for a single run of the protocol.
+type Prover struct {
+	curves      []Curve
+	x           []byte
+	o           *options
+	commitments []Point
+	signatures  []signature
+	// digitCommitments[i] contains the commitments of digit i on each curve
+	digitCommitments [][]commitment
+	rings            []*ringProver
+	state            proverState
+}
+
+type proverState byte
+
+const (
+	proverInit proverState = iota
+	proverCommitted
+	proverResponded
+)
+
+// NewProver returns a new prover for the given secret on the given curves.
+// The witness x must be in little-endian and smaller than the minimum order
+// of the two curves, or smaller than 2^n if a bit length n is set with WithBits.
+// WithRadix and WithBits apply to the interactive protocol; WithCompactEncoding
+// has no effect.
+func NewProver(curveA, curveB Curve, x []byte, opts ...Option) (*Prover, error) {
+	curves := []Curve{curveA, curveB}
+	o, err := newOptions(opts, minBitSize(curves))
+	if err != nil {
+		return nil, err
+	}
+	if len(o.signatureSchemes) != 0 {
+		return nil, errSignatureSchemes
+	}
+
+	x, err = normalizeWitness(x, o.bits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Prover{
+		curves: curves,
+		x:      x,
+		o:      o,
+	}, nil
+}
+
+// Commit generates the prover's commitment message.
+func (p *Prover) Commit() ([]byte, error) {
+	if p.state != proverInit {
+		return nil, errAlreadyCommitted
+	}
+
+	p.commitments = make([]Point, len(p.curves))
+	p.signatures = make([]signature, len(p.curves))
+	for c, curve := range p.curves {
+		secret := scalarFromWitness(curve, p.x)
+		p.commitments[c] = curve.ScalarBaseMul(secret)
+
+		var err error
+		p.signatures[c], err = sign(curve, types.DefaultSignatureScheme, secret, p.commitments[c])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n := numDigits(p.o.bits, p.o.radix)
+	p.digitCommitments = make([][]commitment, n)
+	for i := range p.digitCommitments {
+		p.digitCommitments[i] = make([]commitment, len(p.curves))
+	}
+
+	for c, curve := range p.curves {
+		digitCommitments, err := generateCommitments(curve, p.x, p.o.bits, p.o.radix, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range p.digitCommitments {
+			p.digitCommitments[i][c] = digitCommitments[i]
+		}
+	}
+
+	p.rings = make([]*ringProver, n)
+	for i := range p.rings {
+		var err error
+		p.rings[i], err = newRingProver(
+			p.curves,
+			proofVersion2,
+			nil,
+			getDigit(p.x, p.o.bits, p.o.radix, uint64(i)),
+			digitRingSize(p.o.bits, p.o.radix, uint64(i)),
+			p.digitCommitments[i],
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.state = proverCommitted
+	return p.encodeCommitment(), nil
+}
+
+// Respond generates the prover's response to the verifier's challenge.
+func (p *Prover) Respond(challenge []byte) ([]byte, error) {
+	switch p.state {
+	case proverInit:
+		return nil, errNotCommitted
+	case proverResponded:
+		return nil, errAlreadyResponded
+	}
+
+	if len(challenge) != len(p.rings)*seedLen {
+		return nil, errInvalidChallenge
+	}
+
+	var b []byte
+	for i, rp := range p.rings {
+		seed := append([]byte{}, challenge[i*seedLen:(i+1)*seedLen]...)
+		e, err := challengesFromSeed(p.curves, seed)
+		if err != nil {
+			return nil, err
+		}
+
+		ringSig, err := rp.respond(p.curves, e)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, responses := range ringSig.s {
+			for _, s := range responses {
+				b = append(b, s.Encode()...)
+			}
+		}
+	}
+
+	p.state = proverResponded
+	return b, nil
+}
+
+// encodeCommitment encodes the commitment message.
+//
+// The encoding is: version (1 byte) || radix (1 byte) ||
+// witness bit length (2 bytes, little-endian) || number of curves (1 byte) ||
+// commitment on each curve ||
+// for each curve: signature length (1 byte) || signature ||
+// for each digit: digit commitment on each curve ||
+// R of the last ring member on each curve.
+//
+// The challenge is a 32-byte seed for each digit, and the response is, for
+// each digit and then each curve, the response of each ring member.
+func (p *Prover) encodeCommitment() []byte {
+	digits := &digitProofs{
+		version: proofVersion2,
+		radix:   p.o.radix,
+		bits:    p.o.bits,
+	}
+	b := digits.encodeHeader(len(p.curves))
+	for _, c := range p.commitments {
+		b = append(b, c.Encode()...)
+	}
+	for _, sig := range p.signatures {
+		b = append(b, byte(len(sig.inner)))
+		b = append(b, sig.inner...)
+	}
+
+	for i, rp := range p.rings {
+		for _, c := range p.digitCommitments[i] {
+			b = append(b, c.commitment.Encode()...)
+		}
+		for _, R := range rp.R {
+			b = append(b, R.Encode()...)
+		}
+	}
+
+	return b
+}
+
+// Verifier is the verifier of the interactive version of the proof; see Prover.
+//
+// The verifier's state can be serialized between receiving the prover's
+// commitment and its response, eg. to persist a session.
+type Verifier struct {
+	curves  []Curve
+	minBits uint64
+	// commitmentMsg is the prover's encoded commitment message
+	commitmentMsg []byte
+	commitment    *interactiveCommitment
+	challenge     []byte
+}
+
+// interactiveCommitment is the decoded commitment message.
+type interactiveCommitment struct {
+	header      *header
+	commitments []Point
+	signatures  []signature
+	// digitCommitments[i][c] is the commitment of digit i on curve c
+	digitCommitments [][]Point
+	// R[i][c] is the committed R of the last member of the ring of digit i on curve c
+	R [][]Point
+}
+
+// NewVerifier returns a new verifier for the given curves.
+// By default, the witness must be of the full bit length; see WithMinBits.
+func NewVerifier(curveA, curveB Curve, opts ...VerifyOption) *Verifier {
+	curves := []Curve{curveA, curveB}
+	return &Verifier{
+		curves:  curves,
+		minBits: newVerifyOptions(opts, minBitSize(curves)).minBits,
+	}
+}
+
+// Challenge decodes the prover's commitment message and returns a new random
+// challenge, which must be sent to the prover.
+func (v *Verifier) Challenge(commitmentMsg []byte) ([]byte, error) {
+	commitment, err := v.decodeCommitment(commitmentMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := make([]byte, len(commitment.digitCommitments)*seedLen)
+	_, err = rand.Read(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.setChallenge(commitmentMsg, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// decodeCommitment decodes the commitment message and checks that its bit
+// length is accepted by the verifier.
+func (v *Verifier) decodeCommitment(commitmentMsg []byte) (*interactiveCommitment, error) {
+	commitment, err := decodeInteractiveCommitment(v.curves, commitmentMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBits := minBitSize(v.curves)
+	o := &verifyOptions{
+		minBits: v.minBits,
+	}
+	err = o.checkBits(commitment.header.bits, maxBits)
+	if err != nil {
+		return nil, err
+	}
+
+	return commitment, nil
+}
+
+// setChallenge decodes the commitment message and sets the challenge.
+func (v *Verifier) setChallenge(commitmentMsg, challenge []byte) error {
+	if v.challenge != nil {
+		return errors.New("verifier has already issued a challenge")
+	}
+
+	commitment, err := v.decodeCommitment(commitmentMsg)
+	if err != nil {
+		return err
+	}
+
+	if len(challenge) != len(commitment.digitCommitments)*seedLen {
+		return errInvalidChallenge
+	}
+
+	v.commitmentMsg = append([]byte{}, commitmentMsg...)
+	v.commitment = commitment
+	v.challenge = append([]byte{}, challenge...)
+	return nil
+}
+
+// Verify verifies the prover's response, returning the commitments to the
+// witness on each curve.
+func (v *Verifier) Verify(response []byte) (Point, Point, error) {
+	if v.challenge == nil {
+		return nil, nil, errNoChallenge
+	}
+
+	cm := v.commitment
+	responses, err := decodeInteractiveResponse(v.curves, cm, response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = verifySignatures(v.curves, cm.commitments, cm.signatures)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for c, curve := range v.curves {
+		digitCommitments := make([]commitment, len(cm.digitCommitments))
+		for i := range digitCommitments {
+			digitCommitments[i].commitment = cm.digitCommitments[i][c]
+		}
+
+		err := verifyCommitmentsSum(curve, digitCommitments, cm.commitments[c], cm.header.radix)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to verify commitment on curve %s: %w", curveLabel(c), err)
+		}
+	}
+
+	for i := range cm.digitCommitments {
+		seed := append([]byte{}, v.challenge[i*seedLen:(i+1)*seedLen]...)
+		e, err := challengesFromSeed(v.curves, seed)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// completing the ring from the challenge must lead back to the
+		// committed R
+		R, err := walkRing(v.curves, cm.header.version, nil, cm.digitCommitments[i], e, responses[i])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for c := range v.curves {
+			if !R[c].Equals(cm.R[i][c]) {
+				return nil, nil, fmt.Errorf("failed to verify digit %d: %w", i, errInteractiveVerify)
+			}
+		}
+	}
+
+	return cm.commitments[0], cm.commitments[1], nil
+}
+
+func decodeInteractiveCommitment(curves []types.Curve, in []byte) (*interactiveCommitment, error) {
+	d := newDecoder(in)
+	h, err := d.readHeader(len(curves))
+	if err != nil {
+		return nil, err
+	}
+
+	if h.version != proofVersion2 {
+		return nil, fmt.Errorf("unsupported interactive proof version %d", h.version)
+	}
+
+	maxBits := minBitSize(curves)
+	if h.bits == 0 || h.bits > maxBits {
+		return nil, fmt.Errorf("bit length must be between 1 and %d, got %d", maxBits, h.bits)
+	}
+
+	cm := &interactiveCommitment{
+		header: h,
+	}
+	cm.commitments, err = d.readCommitments(curves)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.signatures, err = d.readSignatures(len(curves))
+	if err != nil {
+		return nil, err
+	}
+
+	n := numDigits(h.bits, h.radix)
+	cm.digitCommitments = make([][]Point, n)
+	cm.R = make([][]Point, n)
+	for i := uint64(0); i < n; i++ {
+		cm.digitCommitments[i], err = d.readCommitments(curves)
+		if err != nil {
+			return nil, err
+		}
+
+		cm.R[i], err = d.readCommitments(curves)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = d.finished()
+	if err != nil {
+		return nil, err
+	}
+
+	return cm, nil
+}
+
+// decodeInteractiveResponse decodes the response to the given commitment
+// message. The i'th element of the result contains the responses of the ring
+// of digit i, on each curve.
+func decodeInteractiveResponse(curves []types.Curve, cm *interactiveCommitment, in []byte) ([][][]Scalar, error) {
+	d := newDecoder(in)
+	responses := make([][][]Scalar, len(cm.digitCommitments))
+	for i := range responses {
+		ringSize := digitRingSize(cm.header.bits, cm.header.radix, uint64(i))
+		responses[i] = make([][]Scalar, len(curves))
+		for c, curve := range curves {
+			responses[i][c] = make([]Scalar, ringSize)
+			for j := range responses[i][c] {
+				var err error
+				responses[i][c][j], err = d.readScalar(curve)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	err := d.finished()
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// Serialize encodes the verifier's state.
+//
+// The encoding is: minimum bit length (2 bytes, little-endian) ||
+// challenge length (2 bytes, little-endian) || challenge || commitment message.
+// Before a challenge has been issued, the challenge length is zero and the
+// commitment message is omitted.
+func (v *Verifier) Serialize() []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint16(b[:2], uint16(v.minBits))
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(v.challenge)))
+	b = append(b, v.challenge...)
+	return append(b, v.commitmentMsg...)
+}
+
+// Deserialize decodes the verifier's state for the given curves.
+func (v *Verifier) Deserialize(curveA, curveB types.Curve, in []byte) error {
+	if len(in) < 4 {
+		return errInputBytesTooShort
+	}
+
+	*v = Verifier{
+		curves:  []Curve{curveA, curveB},
+		minBits: uint64(binary.LittleEndian.Uint16(in[:2])),
+	}
+
+	challengeLen := int(binary.LittleEndian.Uint16(in[2:4]))
+	if challengeLen == 0 {
+		if len(in) != 4 {
+			return errors.New("unexpected commitment message without challenge")
+		}
+		return nil
+	}
+
+	if len(in) < 4+challengeLen {
+		return errInputBytesTooShort
+	}
+
+	return v.setChallenge(in[4+challengeLen:], in[4:4+challengeLen])
+}
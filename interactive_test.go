@@ -0,0 +1,155 @@
+package dleq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+)
+
+func TestInteractive(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+
+	prover, err := NewProver(curveA, curveB, x, WithRadix(4))
+	require.NoError(t, err)
+	commitment, err := prover.Commit()
+	require.NoError(t, err)
+	_, err = prover.Commit()
+	require.Error(t, err)
+
+	verifier := NewVerifier(curveA, curveB)
+	challenge, err := verifier.Challenge(commitment)
+	require.NoError(t, err)
+	require.Equal(t, 126*seedLen, len(challenge))
+
+	// the verifier's state can be persisted while waiting for the response
+	state := verifier.Serialize()
+	verifier = new(Verifier)
+	err = verifier.Deserialize(curveA, curveB, state)
+	require.NoError(t, err)
+	require.Equal(t, state, verifier.Serialize())
+
+	response, err := prover.Respond(challenge)
+	require.NoError(t, err)
+	_, err = prover.Respond(challenge)
+	require.Error(t, err)
+
+	XA, XB, err := verifier.Verify(response)
+	require.NoError(t, err)
+	require.True(t, curveA.ScalarBaseMul(curveA.ScalarFromBytes(x)).Equals(XA))
+	require.True(t, curveB.ScalarBaseMul(curveB.ScalarFromBytes(x)).Equals(XB))
+
+	// the response is only valid for the verifier's challenge
+	other := NewVerifier(curveA, curveB)
+	_, err = other.Challenge(commitment)
+	require.NoError(t, err)
+	_, _, err = other.Verify(response)
+	require.Error(t, err)
+
+	// trailing bytes are rejected
+	verifier = new(Verifier)
+	err = verifier.Deserialize(curveA, curveB, state)
+	require.NoError(t, err)
+	_, _, err = verifier.Verify(append(response, 0))
+	require.ErrorIs(t, err, errTrailingBytes)
+	_, err = NewVerifier(curveA, curveB).Challenge(append(commitment, 0))
+	require.ErrorIs(t, err, errTrailingBytes)
+}
+
+// fiatShamirChallenge returns the challenge of the non-interactive protocol,
+// ie. the seed of each digit is the hash of its commitments and committed R.
+func fiatShamirChallenge(t *testing.T, curves []Curve, cm *interactiveCommitment) []byte {
+	var challenge []byte
+	for i := range cm.digitCommitments {
+		e, err := ringChallenges(curves, proofVersion2, nil, cm.digitCommitments[i], cm.R[i])
+		require.NoError(t, err)
+		challenge = append(challenge, e.seed...)
+	}
+
+	return challenge
+}
+
+func TestInteractive_FiatShamir(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	curves := []Curve{curveA, curveB}
+	x, err := generateRandomBits(32)
+	require.NoError(t, err)
+
+	prover, err := NewProver(curveA, curveB, x, WithBits(32), WithRadix(4))
+	require.NoError(t, err)
+	commitmentMsg, err := prover.Commit()
+	require.NoError(t, err)
+
+	// a non-interactive proof is the same protocol, with each digit's
+	// challenge derived by hashing its commitments and committed R
+	cm, err := decodeInteractiveCommitment(curves, commitmentMsg)
+	require.NoError(t, err)
+	challenge := fiatShamirChallenge(t, curves, cm)
+	response, err := prover.Respond(challenge)
+	require.NoError(t, err)
+
+	verifier := NewVerifier(curveA, curveB, WithMinBits(32))
+	err = verifier.setChallenge(commitmentMsg, challenge)
+	require.NoError(t, err)
+	_, _, err = verifier.Verify(response)
+	require.NoError(t, err)
+
+	// the transcript is a compact Proof
+	responses, err := decodeInteractiveResponse(curves, cm, response)
+	require.NoError(t, err)
+	proofs := make([]bitProof, len(cm.digitCommitments))
+	for i := range proofs {
+		proofs[i].commitments = make([]commitment, len(curves))
+		for c := range curves {
+			proofs[i].commitments[c].commitment = cm.digitCommitments[i][c]
+		}
+
+		seed := challenge[i*seedLen : (i+1)*seedLen]
+		e, err := challengesFromSeed(curves, seed)
+		require.NoError(t, err)
+		proofs[i].ringSig = ringSignature{
+			e:    e.e,
+			seed: e.seed,
+			s:    responses[i],
+		}
+	}
+
+	proof := &Proof{
+		CommitmentA: cm.commitments[0],
+		CommitmentB: cm.commitments[1],
+		Commitments: cm.commitments,
+		digitProofs: digitProofs{
+			version: proofVersion2,
+			radix:   4,
+			bits:    32,
+			proofs:  proofs,
+		},
+		signatures: cm.signatures,
+	}
+	err = proof.Verify(curveA, curveB, WithMinBits(32))
+	require.NoError(t, err)
+
+	deser := new(Proof)
+	err = deser.Deserialize(curveA, curveB, proof.Serialize())
+	require.NoError(t, err)
+	err = deser.Verify(curveA, curveB, WithMinBits(32))
+	require.NoError(t, err)
+
+	// the witness bit length is checked against the verifier's minimum
+	_, err = NewVerifier(curveA, curveB).Challenge(commitmentMsg)
+	require.Error(t, err)
+
+	// a tampered response is rejected
+	verifier = NewVerifier(curveA, curveB, WithMinBits(32))
+	err = verifier.setChallenge(commitmentMsg, challenge)
+	require.NoError(t, err)
+	response[len(response)-1] ^= 1
+	_, _, err = verifier.Verify(response)
+	require.Error(t, err)
+}
@@ -0,0 +1,19 @@
+// Package fullrange lets the other packages of this module check the options
+// of a full range proof before generating one, without package dleq exporting
+// the check.
+package fullrange
+
+import (
+	"errors"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// ErrOptions is returned when a full range proof is generated with an option
+// other than WithRadix and WithCompactEncoding.
+var ErrOptions = errors.New("only WithRadix and WithCompactEncoding can be set for full range proofs")
+
+// CheckOptions returns an error if a full range proof can't be generated on
+// the given curves with the given options, which must be a []dleq.Option.
+// It's set by package dleq, which can't be imported by this package.
+var CheckOptions func(curveA, curveB types.Curve, opts interface{}) error
@@ -0,0 +1,27 @@
+// Package littleendian converts between integers and their little-endian
+// encoding, which is the encoding of witnesses.
+package littleendian
+
+import (
+	"math/big"
+)
+
+// Int returns the integer with the given little-endian encoding, such as a
+// witness.
+func Int(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[i] = b[len(b)-1-i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// Bytes returns the little-endian encoding of n in size bytes. n must be
+// non-negative and fit in size bytes.
+func Bytes(n *big.Int, size int) []byte {
+	b := n.FillBytes(make([]byte, size))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
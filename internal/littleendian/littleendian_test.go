@@ -0,0 +1,15 @@
+package littleendian
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLittleEndian(t *testing.T) {
+	b := Bytes(big.NewInt(0x0102), 4)
+	require.Equal(t, []byte{0x02, 0x01, 0, 0}, b)
+	require.Equal(t, big.NewInt(0x0102), Int(b))
+	require.Equal(t, 0, Int(nil).Sign())
+}
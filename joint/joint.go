@@ -0,0 +1,315 @@
+// Package joint implements joint key generation across two curves, where the
+// joint secret is the sum of each party's secret share, and each party proves
+// that its share has the same discrete logarithm on both curves.
+//
+// For example, in an XMR swap the joint Monero spend key is the sum of both
+// parties' keys, and each party proves its key on secp256k1 and ed25519.
+//
+// This package doesn't produce a single dleq.Proof for the joint secret, and
+// there's no two-party protocol for one. A proof commits to each digit of the
+// witness, and the digits of a sum depend on the carries between the shares,
+// so generating it without either party learning the other's share requires
+// generic two-party computation.
+//
+// Per-share proofs are not a substitute for such a proof. A verifier must have
+// every share, check each one's proof and session binding, and check that the
+// sum of the shares can't wrap around the order of either curve, as Combine
+// does; only then do the joint keys have the same discrete logarithm. The
+// shares' public keys are revealed, and the joint secret is only shown to be
+// smaller than the number of shares times 2^n, not 2^n.
+package joint
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	dleq "github.com/athanorlabs/go-dleq"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+const sessionDomain = "go-dleq/joint-session"
+
+var (
+	errDuplicateShare = errors.New("shares must be distinct")
+	errNoShares       = errors.New("at least one share is required")
+)
+
+// Session is a joint key generation session between two or more parties.
+// Each party's share is bound to the session ID, so it can't be replayed in
+// another session.
+type Session struct {
+	curveA, curveB types.Curve
+	id             []byte
+	bits           uint64
+}
+
+// NewSession returns a new session with the given ID, which must be unique
+// for each session. Shares are at most the given number of bits.
+//
+// The joint secret is the sum of the shares. For its commitments on both
+// curves to have the same discrete logarithm, the sum must not wrap around
+// the order of either curve. The sum of two shares of n bits is less than
+// 2^(n+1), so two parties require n+1 to be at most the minimum of the
+// curves' BitSize, eg. n <= 251 for secp256k1 and ed25519.
+func NewSession(curveA, curveB types.Curve, id []byte, bits uint64) (*Session, error) {
+	if len(id) == 0 {
+		return nil, errors.New("session ID must not be empty")
+	}
+
+	maxBits := maxShareBits(curveA, curveB)
+	if bits == 0 || bits > maxBits {
+		return nil, fmt.Errorf("share bit length must be between 1 and %d, got %d", maxBits, bits)
+	}
+
+	return &Session{
+		curveA: curveA,
+		curveB: curveB,
+		id:     append([]byte{}, id...),
+		bits:   bits,
+	}, nil
+}
+
+// maxShareBits returns the maximum bit length of a share such that the sum of
+// two shares is smaller than the order of both curves.
+func maxShareBits(curveA, curveB types.Curve) uint64 {
+	bits := curveA.BitSize()
+	if curveB.BitSize() < bits {
+		bits = curveB.BitSize()
+	}
+
+	return bits - 1
+}
+
+// Share is a party's contribution to the joint key.
+type Share struct {
+	Proof *dleq.Proof
+	// sessionSigs contains a signature on each curve by the share's
+	// commitment, binding it to the session.
+	sessionSigs [2][]byte
+}
+
+// GenerateSecret generates a random secret share of the session's bit length,
+// in little-endian.
+func (s *Session) GenerateSecret() ([]byte, error) {
+	x := make([]byte, (s.bits+7)/8)
+	_, err := rand.Read(x)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := s.bits; i < uint64(len(x))*8; i++ {
+		x[i/8] &^= 1 << (i % 8)
+	}
+
+	return x, nil
+}
+
+// NewShare returns a new share of the joint key for the given secret, which
+// must be in little-endian and smaller than 2^n, where n is the session's bit length.
+func (s *Session) NewShare(x []byte, opts ...dleq.Option) (*Share, error) {
+	opts = append(opts, dleq.WithBits(s.bits))
+	proof, err := dleq.NewProof(s.curveA, s.curveB, x, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the proof checks that x is smaller than 2^n, so any further bytes are zero
+	secret := make([]byte, (s.bits+7)/8)
+	copy(secret, x)
+
+	share := &Share{
+		Proof: proof,
+	}
+	for c, curve := range []types.Curve{s.curveA, s.curveB} {
+		msg, err := s.message(curve, proof)
+		if err != nil {
+			return nil, err
+		}
+
+		share.sessionSigs[c], err = curve.Sign(curve.ScalarFromBytes(secret), msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return share, nil
+}
+
+// message returns the point signed on the given curve to bind the share to
+// the session.
+func (s *Session) message(curve types.Curve, proof *dleq.Proof) (types.Point, error) {
+	preimage := []byte(sessionDomain)
+	preimage = append(preimage, byte(len(s.id)>>8), byte(len(s.id)))
+	preimage = append(preimage, s.id...)
+	preimage = append(preimage, proof.CommitmentA.Encode()...)
+	preimage = append(preimage, proof.CommitmentB.Encode()...)
+	m, err := curve.HashToScalar(preimage)
+	if err != nil {
+		return nil, err
+	}
+
+	return curve.ScalarBaseMul(m), nil
+}
+
+// VerifyShare verifies the share's proof and that it's bound to the session.
+func (s *Session) VerifyShare(share *Share) error {
+	if share.Proof == nil {
+		return errors.New("share is missing its proof")
+	}
+
+	proof := share.Proof
+	if proof.Bits() != s.bits {
+		return fmt.Errorf("share must be %d bits, got %d", s.bits, proof.Bits())
+	}
+
+	err := proof.Verify(s.curveA, s.curveB, dleq.WithMinBits(s.bits))
+	if err != nil {
+		return err
+	}
+
+	commitments := []types.Point{proof.CommitmentA, proof.CommitmentB}
+	for c, curve := range []types.Curve{s.curveA, s.curveB} {
+		msg, err := s.message(curve, proof)
+		if err != nil {
+			return err
+		}
+
+		if !curve.Verify(commitments[c], msg, share.sessionSigs[c]) {
+			return errors.New("share is not bound to the session")
+		}
+	}
+
+	return nil
+}
+
+// Combine verifies the shares and returns the joint public key on each curve,
+// ie. the sum of the shares' commitments. It fails if the sum of the shares
+// could wrap around the order of either curve, in which case the joint keys
+// may not have the same discrete logarithm.
+func (s *Session) Combine(shares ...*Share) (types.Point, types.Point, error) {
+	if len(shares) == 0 {
+		return nil, nil, errNoShares
+	}
+
+	// each share is at most 2^n - 1, so the sum is at most k*(2^n - 1), which
+	// must be smaller than 2^b for the minimum bit size b of the curves.
+	bound := new(big.Int).Lsh(big.NewInt(1), uint(s.bits))
+	bound.Sub(bound, big.NewInt(1))
+	bound.Mul(bound, big.NewInt(int64(len(shares))))
+	if bound.BitLen() > int(maxShareBits(s.curveA, s.curveB)+1) {
+		return nil, nil, fmt.Errorf("sum of %d shares of %d bits could wrap around the curve order", len(shares), s.bits)
+	}
+
+	err := s.verifyShares(shares)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var XA, XB types.Point
+	for i, share := range shares {
+		if i == 0 {
+			XA, XB = share.Proof.CommitmentA.Copy(), share.Proof.CommitmentB.Copy()
+			continue
+		}
+
+		XA = XA.Add(share.Proof.CommitmentA)
+		XB = XB.Add(share.Proof.CommitmentB)
+	}
+
+	return XA, XB, nil
+}
+
+// verifyShares verifies each share, and that no share is given twice.
+func (s *Session) verifyShares(shares []*Share) error {
+	seen := make(map[string]struct{}, len(shares))
+	for i, share := range shares {
+		err := s.VerifyShare(share)
+		if err != nil {
+			return fmt.Errorf("failed to verify share %d: %w", i, err)
+		}
+
+		// a party replaying another party's share would control the joint key
+		key := string(share.Proof.CommitmentA.Encode())
+		if _, has := seen[key]; has {
+			return errDuplicateShare
+		}
+		seen[key] = struct{}{}
+	}
+
+	return nil
+}
+
+// AggregateMuSig2 combines the shares as Combine does, and returns the MuSig2
+// aggregate of the same verified public keys X_iA on curve A, which must be
+// secp256k1, along with the joint public key on curve B, ie. the sum of the
+// shares' public keys X_iB. The public keys are sorted before aggregation, so
+// the order of the shares doesn't matter.
+//
+// The Taproot key is the MuSig2 aggregate of the X_iA, whose discrete
+// logarithm is sum(a_i*x_i) for the MuSig2 coefficients a_i, not the joint
+// secret sum(x_i) of the key on curve B. Both are derived from the same
+// verified shares, so each party signs with the secret of its share on
+// curve A, and the joint secret is the sum of the shares.
+func (s *Session) AggregateMuSig2(shares ...*Share) (*secp256k1.KeyAggContext, types.Point, error) {
+	if _, ok := s.curveA.(*secp256k1.CurveImpl); !ok {
+		return nil, nil, errors.New("curve A must be secp256k1")
+	}
+
+	_, XB, err := s.Combine(shares...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubkeys := make([][]byte, len(shares))
+	for i, share := range shares {
+		pubkeys[i] = share.Proof.CommitmentA.Encode()
+	}
+
+	keyAgg, err := secp256k1.AggregatePublicKeys(secp256k1.SortPublicKeys(pubkeys))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return keyAgg, XB, nil
+}
+
+// Serialize encodes the share.
+//
+// The encoding is: for each curve: signature length (1 byte) || signature ||
+// proof.
+func (s *Share) Serialize() []byte {
+	var b []byte
+	// WARN: this assumes the signature length is less than 256.
+	for _, sig := range s.sessionSigs {
+		b = append(b, byte(len(sig)))
+		b = append(b, sig...)
+	}
+
+	return append(b, s.Proof.Serialize()...)
+}
+
+// Deserialize decodes the share for the given curves.
+func (s *Share) Deserialize(curveA, curveB types.Curve, in []byte) error {
+	var sigs [2][]byte
+	for c := range sigs {
+		if len(in) < 1 || len(in) < 1+int(in[0]) {
+			return errors.New("input bytes too short")
+		}
+
+		sigs[c] = append([]byte{}, in[1:1+int(in[0])]...)
+		in = in[1+int(in[0]):]
+	}
+
+	proof := new(dleq.Proof)
+	err := proof.Deserialize(curveA, curveB, in)
+	if err != nil {
+		return err
+	}
+
+	s.Proof = proof
+	s.sessionSigs = sigs
+	return nil
+}
@@ -0,0 +1,170 @@
+package joint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dleq "github.com/athanorlabs/go-dleq"
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+func TestCombine(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	session, err := NewSession(curveA, curveB, []byte("swap-1"), 251)
+	require.NoError(t, err)
+
+	x1, err := session.GenerateSecret()
+	require.NoError(t, err)
+	x2, err := session.GenerateSecret()
+	require.NoError(t, err)
+
+	share1, err := session.NewShare(x1, dleq.WithRadix(4))
+	require.NoError(t, err)
+	share2, err := session.NewShare(x2, dleq.WithRadix(4))
+	require.NoError(t, err)
+
+	XA, XB, err := session.Combine(share1, share2)
+	require.NoError(t, err)
+
+	// the joint keys have the same discrete logarithm, x1 + x2
+	sumA := curveA.ScalarFromBytes(x1).Add(curveA.ScalarFromBytes(x2))
+	sumB := curveB.ScalarFromBytes(x1).Add(curveB.ScalarFromBytes(x2))
+	require.True(t, curveA.ScalarBaseMul(sumA).Equals(XA))
+	require.True(t, curveB.ScalarBaseMul(sumB).Equals(XB))
+
+	// shares can't be replayed in another session
+	other, err := NewSession(curveA, curveB, []byte("swap-2"), 251)
+	require.NoError(t, err)
+	_, _, err = other.Combine(share1, share2)
+	require.Error(t, err)
+
+	// or by another party in the same session
+	_, _, err = session.Combine(share1, share1)
+	require.ErrorIs(t, err, errDuplicateShare)
+
+	// three shares of 251 bits could wrap
+	_, _, err = session.Combine(share1, share2, share2)
+	require.Error(t, err)
+
+	ser := share2.Serialize()
+	deser := new(Share)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	err = session.VerifyShare(deser)
+	require.NoError(t, err)
+}
+
+func TestNewSession(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	_, err := NewSession(curveA, curveB, []byte("swap"), 252)
+	require.Error(t, err)
+	_, err = NewSession(curveA, curveB, nil, 251)
+	require.Error(t, err)
+
+	// a share must be of the session's bit length
+	session, err := NewSession(curveA, curveB, []byte("swap"), 32)
+	require.NoError(t, err)
+	x, err := session.GenerateSecret()
+	require.NoError(t, err)
+	share, err := session.NewShare(x)
+	require.NoError(t, err)
+	_, _, err = session.Combine(share)
+	require.NoError(t, err)
+
+	wide, err := NewSession(curveA, curveB, []byte("swap"), 64)
+	require.NoError(t, err)
+	err = wide.VerifyShare(share)
+	require.Error(t, err)
+}
+
+func TestAggregateMuSig2(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	session, err := NewSession(curveA, curveB, []byte("swap-1"), 251)
+	require.NoError(t, err)
+
+	secrets := make([][]byte, 2)
+	shares := make([]*Share, 2)
+	for i := range shares {
+		secrets[i], err = session.GenerateSecret()
+		require.NoError(t, err)
+		shares[i], err = session.NewShare(secrets[i], dleq.WithRadix(4))
+		require.NoError(t, err)
+	}
+
+	keyAgg, XB, err := session.AggregateMuSig2(shares...)
+	require.NoError(t, err)
+
+	// the key on curve B is the joint key returned by Combine
+	XA, combinedXB, err := session.Combine(shares...)
+	require.NoError(t, err)
+	require.True(t, combinedXB.Equals(XB))
+
+	// the Taproot key is the MuSig2 aggregate of the same public keys
+	var aggKey types.Point
+	for i, share := range shares {
+		a, err := keyAgg.Coefficient(share.Proof.CommitmentA.Encode())
+		require.NoError(t, err)
+		aX := share.Proof.CommitmentA.ScalarMul(a)
+		if i == 0 {
+			aggKey = aX
+			continue
+		}
+		aggKey = aggKey.Add(aX)
+	}
+	require.True(t, aggKey.Equals(keyAgg.PublicKey()))
+	require.False(t, XA.Equals(keyAgg.PublicKey()))
+
+	// the order of the shares doesn't matter
+	reversed, reversedXB, err := session.AggregateMuSig2(shares[1], shares[0])
+	require.NoError(t, err)
+	require.Equal(t, keyAgg.XOnlyPublicKey(), reversed.XOnlyPublicKey())
+	require.True(t, reversedXB.Equals(XB))
+
+	msg := []byte("cross-chain swap")
+	aggPubkey := keyAgg.XOnlyPublicKey()
+	secNonces := make([][]byte, len(shares))
+	pubNonces := make([][]byte, len(shares))
+	for i, share := range shares {
+		secNonces[i], pubNonces[i], err = secp256k1.GenerateMuSig2Nonce(
+			curveA.ScalarFromBytes(secrets[i]),
+			share.Proof.CommitmentA.Encode(),
+			aggPubkey,
+			msg,
+			nil,
+		)
+		require.NoError(t, err)
+	}
+
+	aggNonce, err := secp256k1.AggregateMuSig2Nonces(pubNonces)
+	require.NoError(t, err)
+	musig, err := secp256k1.NewMuSig2Session(keyAgg, aggNonce, msg)
+	require.NoError(t, err)
+
+	psigs := make([][]byte, len(shares))
+	for i, share := range shares {
+		psigs[i], err = musig.Sign(secNonces[i], curveA.ScalarFromBytes(secrets[i]))
+		require.NoError(t, err)
+		ok := musig.VerifyPartialSignature(psigs[i], pubNonces[i], share.Proof.CommitmentA.Encode())
+		require.True(t, ok)
+	}
+
+	sig, err := musig.AggregatePartialSignatures(psigs)
+	require.NoError(t, err)
+	require.True(t, secp256k1.VerifyBIP340(aggPubkey, msg, sig))
+
+	// shares are verified before aggregation
+	_, _, err = session.AggregateMuSig2(shares[0], shares[0])
+	require.ErrorIs(t, err, errDuplicateShare)
+
+	// curve A must be secp256k1
+	swapped, err := NewSession(curveB, curveA, []byte("swap-1"), 251)
+	require.NoError(t, err)
+	_, _, err = swapped.AggregateMuSig2(shares...)
+	require.Error(t, err)
+}
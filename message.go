@@ -0,0 +1,147 @@
+package dleq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+const messageDomain = "go-dleq/message"
+
+// MessageSignature is a signature of a message by the same key on two
+// curves. It contains a proof that the public keys on both curves have the
+// same discrete logarithm, and a signature of the message on each curve.
+// The signed messages include both public keys, so each signature is bound
+// to the proven cross-curve key and can't be combined with another proof.
+type MessageSignature struct {
+	Proof      *Proof
+	signatures []signature
+}
+
+// SignMessage signs msg with the secret x on both curves, returning the
+// signatures along with a proof that the public keys x*G on both curves have
+// the same discrete logarithm. The secret must be as for NewProof.
+// The options apply to the proof; the message signatures use the same
+// signature schemes as the proof.
+func SignMessage(curveA, curveB Curve, x []byte, msg []byte, opts ...Option) (*MessageSignature, error) {
+	o, err := newOptions(opts, minBitSize([]Curve{curveA, curveB}))
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := NewProof(curveA, curveB, x, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the proof checks that x is smaller than 2^n, so any further bytes are zero
+	x, err = normalizeWitness(x, o.bits)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := make([]signature, 2)
+	for c, curve := range []Curve{curveA, curveB} {
+		m, err := messagePoint(curve, proof, msg)
+		if err != nil {
+			return nil, err
+		}
+
+		signatures[c], err = sign(curve, o.signatureScheme(curve), scalarFromWitness(curve, x), m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &MessageSignature{
+		Proof:      proof,
+		signatures: signatures,
+	}, nil
+}
+
+// messagePoint returns the point signed on the given curve, which is
+// H(domain || X_A || X_B || msg)*G.
+func messagePoint(curve Curve, proof *Proof, msg []byte) (Point, error) {
+	preimage := []byte(messageDomain)
+	preimage = append(preimage, proof.CommitmentA.Encode()...)
+	preimage = append(preimage, proof.CommitmentB.Encode()...)
+	preimage = append(preimage, msg...)
+	m, err := curve.HashToScalar(preimage)
+	if err != nil {
+		return nil, err
+	}
+
+	return curve.ScalarBaseMul(m), nil
+}
+
+// VerifyMessage verifies that sig is a signature of msg on both curves by
+// keys with the same discrete logarithm, returning the public key on each curve.
+// By default, the proof must be for a witness of the full bit length; see WithMinBits.
+func VerifyMessage(curveA, curveB Curve, msg []byte, sig *MessageSignature, opts ...VerifyOption) (Point, Point, error) {
+	if sig.Proof == nil {
+		return nil, nil, errors.New("message signature is missing its proof")
+	}
+
+	if len(sig.signatures) != 2 {
+		return nil, nil, errInvalidNumCommitments
+	}
+
+	proof := sig.Proof
+	err := proof.Verify(curveA, curveB, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commitments := []Point{proof.CommitmentA, proof.CommitmentB}
+	for c, curve := range []Curve{curveA, curveB} {
+		m, err := messagePoint(curve, proof, msg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !sig.signatures[c].verify(curve, commitments[c], m) {
+			return nil, nil, fmt.Errorf("failed to verify message signature on curve %s", curveLabel(c))
+		}
+	}
+
+	return proof.CommitmentA, proof.CommitmentB, nil
+}
+
+// Serialize encodes the message signature.
+//
+// The encoding is: for each curve: signature scheme (1 byte) ||
+// signature length (1 byte) || signature || proof.
+func (s *MessageSignature) Serialize() []byte {
+	var b []byte
+	// WARN: this assumes the signature length is less than 256.
+	for _, sig := range s.signatures {
+		b = append(b, byte(sig.scheme), byte(len(sig.inner)))
+		b = append(b, sig.inner...)
+	}
+
+	return append(b, s.Proof.Serialize()...)
+}
+
+// Deserialize decodes the message signature for the given curves.
+func (s *MessageSignature) Deserialize(curveA, curveB types.Curve, in []byte) error {
+	signatures, err := newDecoder(in).readSchemeSignatures(2)
+	if err != nil {
+		return err
+	}
+
+	sigsLen := 0
+	for _, sig := range signatures {
+		sigsLen += 2 + len(sig.inner)
+	}
+
+	proof := new(Proof)
+	err = proof.Deserialize(curveA, curveB, in[sigsLen:])
+	if err != nil {
+		return err
+	}
+
+	s.Proof = proof
+	s.signatures = signatures
+	return nil
+}
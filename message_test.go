@@ -0,0 +1,70 @@
+package dleq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+func TestSignMessage(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	msg := []byte("swap offer")
+
+	sig, err := SignMessage(curveA, curveB, x, msg, WithRadix(4))
+	require.NoError(t, err)
+	XA, XB, err := VerifyMessage(curveA, curveB, msg, sig)
+	require.NoError(t, err)
+	require.True(t, curveA.ScalarBaseMul(curveA.ScalarFromBytes(x)).Equals(XA))
+	require.True(t, curveB.ScalarBaseMul(curveB.ScalarFromBytes(x)).Equals(XB))
+
+	_, _, err = VerifyMessage(curveA, curveB, []byte("another offer"), sig)
+	require.Error(t, err)
+
+	ser := sig.Serialize()
+	deser := new(MessageSignature)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	_, _, err = VerifyMessage(curveA, curveB, msg, deser)
+	require.NoError(t, err)
+
+	// the signatures are bound to the proof's keys
+	y, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	other, err := SignMessage(curveA, curveB, y, msg, WithRadix(4))
+	require.NoError(t, err)
+	deser.Proof = other.Proof
+	_, _, err = VerifyMessage(curveA, curveB, msg, deser)
+	require.Error(t, err)
+}
+
+func TestSignMessage_SignatureSchemes(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	msg := []byte("swap offer")
+
+	sig, err := SignMessage(curveA, curveB, x, msg,
+		WithRadix(4),
+		WithSignatureScheme(types.BIP340),
+		WithSignatureScheme(types.Monero),
+	)
+	require.NoError(t, err)
+	require.Equal(t, types.BIP340, sig.signatures[0].scheme)
+	require.Equal(t, types.Monero, sig.signatures[1].scheme)
+
+	ser := sig.Serialize()
+	deser := new(MessageSignature)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	_, _, err = VerifyMessage(curveA, curveB, msg, deser)
+	require.NoError(t, err)
+}
@@ -0,0 +1,148 @@
+package dleq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+)
+
+func TestMultiProof(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	xs := make([][]byte, 3)
+	for i := range xs {
+		var err error
+		xs[i], err = GenerateSecretForCurves(curveA, curveB)
+		require.NoError(t, err)
+	}
+
+	proof, err := NewMultiProof(curveA, curveB, xs, WithRadix(4), WithCompactEncoding())
+	require.NoError(t, err)
+	require.Equal(t, 3, proof.Len())
+	for i, x := range xs {
+		require.True(t, curveB.ScalarBaseMul(curveB.ScalarFromBytes(x)).Equals(proof.CommitmentsB[i]))
+	}
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(MultiProof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	// the encoding contains every R, so it is larger than separate proofs,
+	// but less than twice their size
+	single, err := NewProof(curveA, curveB, xs[0], WithRadix(4), WithCompactEncoding())
+	require.NoError(t, err)
+	require.Less(t, len(ser), 2*3*len(single.Serialize()))
+
+	// swapping the commitments of two witnesses invalidates the proof
+	deser.CommitmentsB[0], deser.CommitmentsB[1] = deser.CommitmentsB[1], deser.CommitmentsB[0]
+	err = deser.Verify(curveA, curveB)
+	require.Error(t, err)
+
+	err = deser.Deserialize(curveA, curveB, ser[:len(ser)-1])
+	require.Error(t, err)
+}
+
+func TestMultiProof_Signature(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	xs := make([][]byte, 2)
+	for i := range xs {
+		var err error
+		xs[i], err = generateRandomBits(16)
+		require.NoError(t, err)
+	}
+
+	proof, err := NewMultiProof(curveA, curveB, xs, WithBits(16))
+	require.NoError(t, err)
+	err = proof.Verify(curveA, curveB, WithMinBits(16))
+	require.NoError(t, err)
+
+	// the aggregated signature is bound to every commitment
+	other, err := NewMultiProof(curveA, curveB, xs[:1], WithBits(16))
+	require.NoError(t, err)
+	other.signatures = proof.signatures
+	err = other.Verify(curveA, curveB, WithMinBits(16))
+	require.Error(t, err)
+}
+
+func TestMultiProof_Transcript(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	xs := make([][]byte, 3)
+	for i := range xs {
+		var err error
+		xs[i], err = generateRandomBits(16)
+		require.NoError(t, err)
+	}
+
+	proof, err := NewMultiProof(curveA, curveB, xs[:2], WithBits(16))
+	require.NoError(t, err)
+	other, err := NewMultiProof(curveA, curveB, [][]byte{xs[0], xs[2]}, WithBits(16))
+	require.NoError(t, err)
+	err = other.Verify(curveA, curveB, WithMinBits(16))
+	require.NoError(t, err)
+
+	// the digit proofs of a witness are only valid with the other statements
+	// they were generated with
+	proof.proofs[0] = other.proofs[0]
+	err = proof.Verify(curveA, curveB, WithMinBits(16))
+	require.ErrorIs(t, err, errMultiProofInvalid)
+}
+
+func TestMultiProof_Tampered(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	xs := make([][]byte, 2)
+	for i := range xs {
+		var err error
+		xs[i], err = generateRandomBits(16)
+		require.NoError(t, err)
+	}
+
+	proof, err := NewMultiProof(curveA, curveB, xs, WithBits(16))
+	require.NoError(t, err)
+	ser := proof.Serialize()
+
+	// every response and R is checked
+	d := proof.proofs[1][3]
+	d.s[1][0] = d.s[1][0].Add(curveB.ScalarFromInt(1))
+	err = proof.Verify(curveA, curveB, WithMinBits(16))
+	require.ErrorIs(t, err, errMultiProofInvalid)
+
+	err = proof.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	d = proof.proofs[0][5]
+	d.R[1][0] = d.R[1][0].Add(curveA.BasePoint())
+	err = proof.Verify(curveA, curveB, WithMinBits(16))
+	require.ErrorIs(t, err, errMultiProofInvalid)
+
+	// the digit commitments must sum to the commitment
+	err = proof.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	proof.CommitmentsB[1] = proof.CommitmentsB[1].Add(curveB.BasePoint())
+	err = proof.Verify(curveA, curveB, WithMinBits(16))
+	require.Error(t, err)
+
+	err = proof.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	err = proof.Verify(curveA, curveB, WithMinBits(16))
+	require.NoError(t, err)
+}
+
+func TestMultiProof_SerializeEmpty(t *testing.T) {
+	require.Nil(t, new(MultiProof).Serialize())
+
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	err := new(MultiProof).Verify(curveA, curveB)
+	require.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package dleq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+const (
+	defaultRadix = 2
+	maxRadix     = 128
+)
+
+// Option configures the generation of a proof.
+type Option func(*options)
+
+type options struct {
+	radix            uint64
+	compact          bool
+	bits             uint64
+	signatureSchemes []types.SignatureScheme
+	// transcript is prepended to the preimage of every ring challenge. It's
+	// set by NewMultiProof rather than by an Option.
+	transcript []byte
+}
+
+// newOptions applies the given options. maxBits is the maximum bit length of
+// a witness on both curves, which is also the default.
+func newOptions(opts []Option, maxBits uint64) (*options, error) {
+	o := applyOptions(opts, maxBits)
+	err := checkRadix(o.radix)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.bits == 0 || o.bits > maxBits {
+		return nil, fmt.Errorf("bit length must be between 1 and %d, got %d", maxBits, o.bits)
+	}
+
+	return o, nil
+}
+
+// applyOptions applies the given options without checking them.
+func applyOptions(opts []Option, maxBits uint64) *options {
+	o := &options{
+		radix: defaultRadix,
+		bits:  maxBits,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithRadix sets the radix of the digits the witness is decomposed into.
+// Each digit is proven with a ring signature of size radix, so a larger radix
+// results in fewer, larger ring signatures. The radix must be a power of two
+// between 2 and 128; the default is 2, ie. one ring signature per bit.
+func WithRadix(radix uint64) Option {
+	return func(o *options) {
+		o.radix = radix
+	}
+}
+
+func checkRadix(radix uint64) error {
+	if radix < 2 || radix > maxRadix || radix&(radix-1) != 0 {
+		return fmt.Errorf("radix must be a power of two between 2 and %d, got %d", maxRadix, radix)
+	}
+
+	return nil
+}
+
+// WithCompactEncoding generates a compact (version 2) proof.
+// Compact proofs derive the challenges on both curves from a single hash
+// output, so only one hash is encoded per digit instead of one scalar per
+// curve, and omit the last digit commitment on each curve, which the verifier
+// reconstructs from the commitment to the witness and the other digit commitments.
+func WithCompactEncoding() Option {
+	return func(o *options) {
+		o.compact = true
+	}
+}
+
+// WithBits sets the bit length n of the witness, ie. the proof shows that
+// x < 2^n. The size of the proof and the time taken to generate and verify it
+// are linear in n. The bit length is part of the statement and is recorded in
+// the serialized proof; see WithMinBits. The default is the minimum of
+// the curves' BitSize.
+func WithBits(bits uint64) Option {
+	return func(o *options) {
+		o.bits = bits
+	}
+}
+
+// WithSignatureScheme sets the signature scheme of the proof of knowledge of
+// the witness on the curves which support it; see types.SchemeSigner. It can
+// be set more than once, in which case each curve uses the first scheme it
+// supports. Curves which support none of them use their default scheme.
+// The scheme of each signature is recorded in the serialized proof.
+// Only proofs generated with NewProof and NewProofForCurves can set it.
+func WithSignatureScheme(scheme types.SignatureScheme) Option {
+	return func(o *options) {
+		o.signatureSchemes = append(o.signatureSchemes, scheme)
+	}
+}
+
+var errSignatureSchemes = errors.New("signature schemes can only be set for proofs generated with NewProof")
+
+// signatureScheme returns the signature scheme of the proof of knowledge on
+// the given curve.
+func (o *options) signatureScheme(curve Curve) types.SignatureScheme {
+	signer, ok := curve.(types.SchemeSigner)
+	if !ok {
+		return types.DefaultSignatureScheme
+	}
+
+	for _, scheme := range o.signatureSchemes {
+		if signer.SupportsSignatureScheme(scheme) {
+			return scheme
+		}
+	}
+
+	return types.DefaultSignatureScheme
+}
+
+// checkSignatureSchemes checks that every signature scheme that was set is
+// supported by at least one of the curves.
+func (o *options) checkSignatureSchemes(curves []Curve) error {
+	for _, scheme := range o.signatureSchemes {
+		supported := false
+		for _, curve := range curves {
+			signer, ok := curve.(types.SchemeSigner)
+			if ok && signer.SupportsSignatureScheme(scheme) {
+				supported = true
+				break
+			}
+		}
+
+		if !supported {
+			return fmt.Errorf("signature scheme %d is not supported by any of the curves", scheme)
+		}
+	}
+
+	return nil
+}
+
+// VerifyOption configures the verification of a proof.
+type VerifyOption func(*verifyOptions)
+
+type verifyOptions struct {
+	minBits uint64
+}
+
+// newVerifyOptions applies the given options. maxBits is the maximum bit
+// length of a witness on both curves, which is also the default minimum.
+func newVerifyOptions(opts []VerifyOption, maxBits uint64) *verifyOptions {
+	o := &verifyOptions{
+		minBits: maxBits,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithMinBits sets the minimum bit length of the witness accepted by the
+// verifier. By default, only proofs for witnesses of the full bit length
+// (the minimum of the curves' BitSize) are accepted, so that a prover can't
+// weaken the statement by proving a smaller witness.
+func WithMinBits(bits uint64) VerifyOption {
+	return func(o *verifyOptions) {
+		o.minBits = bits
+	}
+}
+
+// checkBits checks that the bit length of a proof is accepted.
+func (o *verifyOptions) checkBits(bits, maxBits uint64) error {
+	if bits > maxBits {
+		return fmt.Errorf("bit length must be at most %d, got %d", maxBits, bits)
+	}
+
+	if bits < o.minBits || bits == 0 {
+		return fmt.Errorf("bit length must be at least %d, got %d", o.minBits, bits)
+	}
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package dleq
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// p384Curve is a minimal types.Curve for P-384, which has 48-byte scalars,
+// used to test witnesses larger than 256 bits. It is not constant-time.
+type p384Curve struct {
+	params *elliptic.CurveParams
+	alt    *p384Point
+}
+
+type p384Scalar struct {
+	curve *p384Curve
+	inner *big.Int
+}
+
+type p384Point struct {
+	curve *p384Curve
+	// the identity is represented as (0, 0)
+	x, y *big.Int
+}
+
+func newP384Curve() *p384Curve {
+	c := &p384Curve{
+		params: elliptic.P384().Params(),
+	}
+
+	// try-and-increment hash to curve, so the discrete log is unknown
+	for ctr := byte(0); ; ctr++ {
+		h := sha3.Sum384([]byte{'p', '3', '8', '4', ctr})
+		p, err := c.DecodeToPoint(append([]byte{2}, h[:]...))
+		if err == nil {
+			c.alt = p.(*p384Point)
+			return c
+		}
+	}
+}
+
+func (c *p384Curve) scalar(n *big.Int) *p384Scalar {
+	return &p384Scalar{c, new(big.Int).Mod(n, c.params.N)}
+}
+
+func (c *p384Curve) point(x, y *big.Int) *p384Point {
+	return &p384Point{c, x, y}
+}
+
+func (*p384Curve) BitSize() uint64             { return 383 }
+func (*p384Curve) CompressedPointSize() int    { return 49 }
+func (*p384Curve) ScalarSize() int             { return 48 }
+func (c *p384Curve) BasePoint() types.Point    { return c.point(c.params.Gx, c.params.Gy) }
+func (c *p384Curve) AltBasePoint() types.Point { return c.alt }
+func (c *p384Curve) ScalarFromInt(in uint32) types.Scalar {
+	return c.scalar(new(big.Int).SetUint64(uint64(in)))
+}
+
+func (c *p384Curve) NewRandomScalar() types.Scalar {
+	n, err := rand.Int(rand.Reader, c.params.N)
+	if err != nil {
+		panic(err)
+	}
+	return c.scalar(n)
+}
+
+func (c *p384Curve) ScalarFromBytes(b []byte) types.Scalar {
+	if len(b) > 48 {
+		panic("scalar encoding must be at most 48 bytes")
+	}
+	return c.scalar(new(big.Int).SetBytes(reverseBytes(b)))
+}
+
+func (c *p384Curve) HashToScalar(in []byte) (types.Scalar, error) {
+	h := sha3.Sum512(in)
+	return c.scalar(new(big.Int).SetBytes(h[:])), nil
+}
+
+func (c *p384Curve) ScalarBaseMul(s types.Scalar) types.Point {
+	return c.BasePoint().ScalarMul(s)
+}
+
+func (c *p384Curve) ScalarMul(s types.Scalar, p types.Point) types.Point {
+	return p.ScalarMul(s)
+}
+
+// Sign creates a Schnorr signature of the encoded point p.
+func (c *p384Curve) Sign(s types.Scalar, p types.Point) ([]byte, error) {
+	k, err := c.HashToScalar(append(s.Encode(), p.Encode()...))
+	if err != nil {
+		return nil, err
+	}
+
+	R := c.ScalarBaseMul(k)
+	e, err := c.HashToScalar(append(append(R.Encode(), c.ScalarBaseMul(s).Encode()...), p.Encode()...))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(R.Encode(), k.Add(e.Mul(s)).Encode()...), nil
+}
+
+func (c *p384Curve) Verify(pubkey, msgPoint types.Point, sig []byte) bool {
+	if len(sig) != 49+48 {
+		return false
+	}
+
+	R, err := c.DecodeToPoint(sig[:49])
+	if err != nil {
+		return false
+	}
+
+	s, err := c.DecodeToScalar(sig[49:])
+	if err != nil {
+		return false
+	}
+
+	e, err := c.HashToScalar(append(append(R.Encode(), pubkey.Encode()...), msgPoint.Encode()...))
+	if err != nil {
+		return false
+	}
+
+	return c.ScalarBaseMul(s).Equals(R.Add(pubkey.ScalarMul(e)))
+}
+
+func (c *p384Curve) DecodeToPoint(in []byte) (types.Point, error) {
+	if len(in) != 49 {
+		return nil, errors.New("invalid point length")
+	}
+
+	if new(big.Int).SetBytes(in).Sign() == 0 {
+		return c.point(new(big.Int), new(big.Int)), nil
+	}
+
+	x, y := elliptic.UnmarshalCompressed(elliptic.P384(), in)
+	if x == nil {
+		return nil, errors.New("invalid point")
+	}
+	return c.point(x, y), nil
+}
+
+func (c *p384Curve) DecodeToScalar(in []byte) (types.Scalar, error) {
+	if len(in) != 48 {
+		return nil, errors.New("invalid scalar length")
+	}
+	n := new(big.Int).SetBytes(reverseBytes(in))
+	if n.Cmp(c.params.N) >= 0 {
+		return nil, errors.New("scalar is not reduced")
+	}
+	return c.scalar(n), nil
+}
+
+func (s *p384Scalar) other(b types.Scalar) *big.Int { return b.(*p384Scalar).inner }
+
+func (s *p384Scalar) Add(b types.Scalar) types.Scalar {
+	return s.curve.scalar(new(big.Int).Add(s.inner, s.other(b)))
+}
+
+func (s *p384Scalar) Sub(b types.Scalar) types.Scalar {
+	return s.curve.scalar(new(big.Int).Sub(s.inner, s.other(b)))
+}
+
+func (s *p384Scalar) Negate() types.Scalar {
+	return s.curve.scalar(new(big.Int).Neg(s.inner))
+}
+
+func (s *p384Scalar) Mul(b types.Scalar) types.Scalar {
+	return s.curve.scalar(new(big.Int).Mul(s.inner, s.other(b)))
+}
+
+func (s *p384Scalar) Inverse() types.Scalar {
+	return s.curve.scalar(new(big.Int).ModInverse(s.inner, s.curve.params.N))
+}
+
+func (s *p384Scalar) Encode() []byte {
+	b := make([]byte, 48)
+	s.inner.FillBytes(b)
+	return reverseBytes(b)
+}
+
+func (s *p384Scalar) Eq(b types.Scalar) bool { return s.inner.Cmp(s.other(b)) == 0 }
+func (s *p384Scalar) IsZero() bool           { return s.inner.Sign() == 0 }
+
+func (p *p384Point) Copy() types.Point {
+	return p.curve.point(new(big.Int).Set(p.x), new(big.Int).Set(p.y))
+}
+
+func (p *p384Point) Add(b types.Point) types.Point {
+	q := b.(*p384Point)
+	switch {
+	case p.IsZero():
+		return q.Copy()
+	case q.IsZero():
+		return p.Copy()
+	case p.x.Cmp(q.x) == 0 && p.y.Cmp(q.y) != 0:
+		return p.curve.point(new(big.Int), new(big.Int))
+	}
+
+	x, y := elliptic.P384().Add(p.x, p.y, q.x, q.y)
+	return p.curve.point(x, y)
+}
+
+func (p *p384Point) Sub(b types.Point) types.Point {
+	q := b.(*p384Point)
+	if q.IsZero() {
+		return p.Copy()
+	}
+
+	negY := new(big.Int).Sub(p.curve.params.P, q.y)
+	return p.Add(p.curve.point(q.x, negY))
+}
+
+func (p *p384Point) ScalarMul(s types.Scalar) types.Point {
+	k := s.(*p384Scalar).inner
+	if p.IsZero() || k.Sign() == 0 {
+		return p.curve.point(new(big.Int), new(big.Int))
+	}
+
+	x, y := elliptic.P384().ScalarMult(p.x, p.y, k.Bytes())
+	return p.curve.point(x, y)
+}
+
+func (p *p384Point) Encode() []byte {
+	if p.IsZero() {
+		return make([]byte, 49)
+	}
+	return elliptic.MarshalCompressed(elliptic.P384(), p.x, p.y)
+}
+
+func (p *p384Point) IsZero() bool { return p.x.Sign() == 0 && p.y.Sign() == 0 }
+
+func (p *p384Point) Equals(other types.Point) bool {
+	q := other.(*p384Point)
+	return p.x.Cmp(q.x) == 0 && p.y.Cmp(q.y) == 0
+}
+
+func reverseBytes(in []byte) []byte {
+	out := make([]byte, len(in))
+	for i := range in {
+		out[i] = in[len(in)-1-i]
+	}
+	return out
+}
+
+func TestProveAndVerify_LargeScalars(t *testing.T) {
+	curves := []Curve{newP384Curve(), newP384Curve()}
+	x, err := GenerateSecretForCurves(curves...)
+	require.NoError(t, err)
+	require.Equal(t, 48, len(x))
+
+	// a witness of more than 256 bits, but fewer than the full 383 bits to
+	// keep the test fast
+	const bits = 264
+	x, err = generateRandomBits(bits)
+	require.NoError(t, err)
+	x[32] |= 0x80
+
+	proof, err := NewProofForCurves(curves, x, WithRadix(4), WithBits(bits))
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(Proof)
+	err = deser.DeserializeForCurves(curves, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = deser.VerifyForCurves(curves, WithMinBits(bits))
+	require.NoError(t, err)
+}
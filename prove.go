@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"github.com/athanorlabs/go-dleq/types"
+
+	"golang.org/x/crypto/sha3"
 )
 
 type Curve = types.Curve
@@ -15,18 +17,32 @@ type Scalar = types.Scalar
 // Proof represents a DLEq proof and commitment to the witness.
 type Proof struct {
 	CommitmentA, CommitmentB Point
-	proofs                   []bitProof
-	signatureA, signatureB   signature
+	// Commitments contains the commitment to the witness on each curve the
+	// proof is for, in order. CommitmentA and CommitmentB are the first two.
+	Commitments []Point
+	digitProofs
+	signatures []signature
+}
+
+// digitProofs contains the digit commitments and ring signatures which prove
+// that commitments on each curve commit to the same value in [0, 2^bits).
+type digitProofs struct {
+	version byte
+	radix   uint64
+	bits    uint64
+	proofs  []bitProof
 }
 
 type signature struct {
-	inner []byte
+	inner  []byte
+	scheme types.SignatureScheme
 }
 
-// bitProof represents the proof for 1 bit of the witness.
+// bitProof represents the proof for 1 digit of the witness.
+// With the default radix of 2, each digit is a single bit.
 type bitProof struct {
-	commitmentA, commitmentB commitment
-	ringSig                  ringSignature
+	commitments []commitment // one per curve
+	ringSig     ringSignature
 }
 
 type commitment struct {
@@ -36,109 +52,201 @@ type commitment struct {
 	commitment Point
 }
 
+// ringSignature proves that the commitments on each curve all commit to the
+// same digit j, ie. for some j, C - j*G is a multiple of the alternate base
+// point on every curve.
+// The ring has one member for each possible value of the digit.
 type ringSignature struct {
-	eCurveA, eCurveB Scalar
-	a0, a1           Scalar // in A
-	b0, b1           Scalar // in B
+	// e contains the challenge on each curve for the first ring member.
+	e []Scalar
+	// seed is the hash the challenges are derived from. It's only set for
+	// compact proofs, where it's encoded instead of the challenges.
+	seed []byte
+	// s contains the responses on each curve, one per ring member.
+	s [][]Scalar
+}
+
+// ringChallenge is the challenge for a ring member on every curve.
+type ringChallenge struct {
+	e    []Scalar
+	seed []byte
+}
+
+// Bits returns the bit length n of the witness, ie. the proof shows that x < 2^n.
+func (p *digitProofs) Bits() uint64 {
+	return p.bits
 }
 
 // GenerateSecretForCurves generates a secret value that has a corresponding
-// commitment on both curves.
-func GenerateSecretForCurves(curveA, curveB Curve) ([32]byte, error) {
-	bits := min(curveA.BitSize(), curveB.BitSize())
-	return generateRandomBits(bits)
+// commitment on all the given curves.
+// The secret is in little-endian and smaller than 2^n, where n is the
+// minimum of the curves' BitSize.
+func GenerateSecretForCurves(curves ...Curve) ([]byte, error) {
+	return generateRandomBits(minBitSize(curves))
 }
 
 // NewProof returns a new proof for the given secret on the given curves.
 // The witness x must be in little-endian and smaller than the minimum order
-// of the two curves.
-func NewProof(curveA, curveB Curve, x [32]byte) (*Proof, error) {
-	bits := min(curveA.BitSize(), curveB.BitSize())
+// of the two curves, or smaller than 2^n if a bit length n is set with WithBits.
+func NewProof(curveA, curveB Curve, x []byte, opts ...Option) (*Proof, error) {
+	return NewProofForCurves([]Curve{curveA, curveB}, x, opts...)
+}
 
-	err := checkWitnessSize(x, bits)
+// NewProofForCurves returns a new proof that the discrete logarithms of the
+// commitments to the witness on each of the given curves are equal.
+// The bit commitments are shared, so the proof size is linear in the number of
+// curves. The witness x must be in little-endian and smaller than the minimum
+// order of the curves, or smaller than 2^n if a bit length n is set with WithBits.
+func NewProofForCurves(curves []Curve, x []byte, opts ...Option) (*Proof, error) {
+	if len(curves) < 2 {
+		return nil, errTooFewCurves
+	}
+
+	o, err := newOptions(opts, minBitSize(curves))
 	if err != nil {
 		return nil, err
 	}
 
-	xA := curveA.ScalarFromBytes(x)
-	xB := curveB.ScalarFromBytes(x)
-	XA := curveA.ScalarBaseMul(xA)
-	XB := curveB.ScalarBaseMul(xB)
-
-	// generate commitments for each curve
-	commitmentsA, err := generateCommitments(curveA, x[:], bits)
+	x, err = normalizeWitness(x, o.bits)
 	if err != nil {
 		return nil, err
 	}
 
-	err = verifyCommitmentsSum(curveA, commitmentsA, XA)
+	secrets := make([]Scalar, len(curves))
+	commitments := make([]Point, len(curves))
+	for c, curve := range curves {
+		secrets[c] = scalarFromWitness(curve, x)
+		commitments[c] = curve.ScalarBaseMul(secrets[c])
+	}
+
+	digits, err := newDigitProofs(curves, x, o, nil, commitments)
 	if err != nil {
 		return nil, err
 	}
 
-	commitmentsB, err := generateCommitments(curveB, x[:], bits)
+	err = o.checkSignatureSchemes(curves)
 	if err != nil {
 		return nil, err
 	}
 
-	err = verifyCommitmentsSum(curveB, commitmentsB, XB)
+	signatures := make([]signature, len(curves))
+	for c, curve := range curves {
+		signatures[c], err = sign(curve, o.signatureScheme(curve), secrets[c], commitments[c])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Proof{
+		CommitmentA: commitments[0],
+		CommitmentB: commitments[1],
+		Commitments: commitments,
+		digitProofs: *digits,
+		signatures:  signatures,
+	}, nil
+}
+
+// sign generates a signature of the commitment p by its discrete logarithm s
+// with the given scheme, which proves knowledge of s.
+func sign(curve Curve, scheme types.SignatureScheme, s Scalar, p Point) (signature, error) {
+	if scheme == types.DefaultSignatureScheme {
+		sig, err := curve.Sign(s, p)
+		return signature{inner: sig}, err
+	}
+
+	signer, ok := curve.(types.SchemeSigner)
+	if !ok || !signer.SupportsSignatureScheme(scheme) {
+		return signature{}, fmt.Errorf("unsupported signature scheme %d", scheme)
+	}
+
+	sig, err := signer.SignWithScheme(scheme, s, p)
+	return signature{
+		inner:  sig,
+		scheme: scheme,
+	}, err
+}
+
+// newDigitProofs generates the digit commitments and ring signatures for the
+// witness x on each curve. The digit commitments on each curve sum to the
+// corresponding commitment, x*G + r*H, where r is the corresponding blinder.
+// If blinders is nil, each r is zero.
+func newDigitProofs(curves []Curve, x []byte, o *options, blinders []Scalar, commitments []Point) (*digitProofs, error) {
+	version := proofVersion1
+	if o.compact {
+		version = proofVersion2
+	}
+
+	bits := o.bits
+
+	x, err := normalizeWitness(x, bits)
 	if err != nil {
 		return nil, err
 	}
 
-	proofs := make([]bitProof, bits)
+	n := numDigits(bits, o.radix)
+	proofs := make([]bitProof, n)
+	for i := range proofs {
+		proofs[i].commitments = make([]commitment, len(curves))
+	}
 
-	for i := 0; i < int(bits); i++ {
-		bit := getBit(x[:], uint64(i))
-		ringSig, err := generateRingSignature(curveA, curveB, bit, commitmentsA[i], commitmentsB[i])
+	for c, curve := range curves {
+		var blinder Scalar
+		if blinders != nil {
+			blinder = blinders[c]
+		}
+
+		// generate commitments for each curve
+		digitCommitments, err := generateCommitments(curve, x, bits, o.radix, blinder)
 		if err != nil {
 			return nil, err
 		}
 
-		proofs[i] = bitProof{
-			commitmentA: commitmentsA[i],
-			commitmentB: commitmentsB[i],
-			ringSig:     *ringSig,
+		err = verifyCommitmentsSum(curve, digitCommitments, commitments[c], o.radix)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	sigA, err := curveA.Sign(xA, XA)
-	if err != nil {
-		return nil, err
+		for i := range proofs {
+			proofs[i].commitments[c] = digitCommitments[i]
+		}
 	}
 
-	sigB, err := curveB.Sign(xB, XB)
-	if err != nil {
-		return nil, err
+	for i := range proofs {
+		digit := getDigit(x, bits, o.radix, uint64(i))
+		ringSize := digitRingSize(bits, o.radix, uint64(i))
+		ringSig, err := generateRingSignature(
+			curves,
+			version,
+			o.transcript,
+			digit, ringSize,
+			proofs[i].commitments,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		proofs[i].ringSig = *ringSig
 	}
 
-	return &Proof{
-		CommitmentA: XA,
-		CommitmentB: XB,
-		proofs:      proofs,
-		signatureA: signature{
-			sigA,
-		},
-		signatureB: signature{
-			sigB,
-		},
+	return &digitProofs{
+		version: version,
+		radix:   o.radix,
+		bits:    bits,
+		proofs:  proofs,
 	}, nil
 }
 
-func checkWitnessSize(x [32]byte, bits uint64) error {
-	// number of leading bits that should be cleared
-	cleared := 256 - bits
+func checkWitnessSize(x []byte, bits uint64) error {
+	if bits/8 >= uint64(len(x)) {
+		return nil
+	}
 
 	// zero out bits that don't have to be zero
-	bitmask := byte(0xff) << (8 - cleared%8)
+	bitmask := byte(0xff) << (bits % 8)
 	if x[bits/8]&bitmask != 0 {
 		return fmt.Errorf("secret must be under %d bits", bits)
 	}
 
-	if cleared/8 == 0 {
-		return nil
-	}
-
 	for _, b := range x[(bits/8)+1:] {
 		if b != 0 {
 			return fmt.Errorf("secret must be under %d bits", bits)
@@ -148,85 +256,164 @@ func checkWitnessSize(x [32]byte, bits uint64) error {
 	return nil
 }
 
-// verifyCommitmentsSum verifies that all the commitments sum to the given point.
-func verifyCommitmentsSum(curve Curve, commitments []commitment, point Point) error {
-	sum := commitments[0].commitment.Copy()
+// normalizeWitness checks that the little-endian witness is smaller than
+// 2^bits, and returns a copy of it of exactly ceil(bits/8) bytes.
+func normalizeWitness(x []byte, bits uint64) ([]byte, error) {
+	err := checkWitnessSize(x, bits)
+	if err != nil {
+		return nil, err
+	}
 
-	two := curve.ScalarFromInt(2)
-	currPowerOfTwo := curve.ScalarFromInt(2)
+	normalized := make([]byte, (bits+7)/8)
+	copy(normalized, x)
+	return normalized, nil
+}
 
-	for _, c := range commitments[1:] {
-		sum = sum.Add(c.commitment.ScalarMul(currPowerOfTwo))
-		currPowerOfTwo = currPowerOfTwo.Mul(two)
+// scalarFromWitness returns the little-endian witness as a scalar on the
+// curve. Trailing zero bytes beyond the curve's scalar size are ignored.
+func scalarFromWitness(curve Curve, x []byte) Scalar {
+	for len(x) > curve.ScalarSize() && x[len(x)-1] == 0 {
+		x = x[:len(x)-1]
+	}
+
+	return curve.ScalarFromBytes(x)
+}
+
+var (
+	errCommitmentsSum = errors.New("commitments do not sum to given point")
+	errTooFewCurves   = errors.New("at least two curves are required")
+)
+
+// verifyCommitmentsSum verifies that all the commitments sum to the given point.
+func verifyCommitmentsSum(curve Curve, commitments []commitment, point Point, radix uint64) error {
+	sum := newCommitmentSum(curve, radix)
+	for _, c := range commitments {
+		sum.add(c.commitment)
 	}
 
-	if sum.Equals(point) {
+	if sum.equals(point) {
 		return nil
 	}
 
-	return errors.New("commitments do not sum to given point")
+	return errCommitmentsSum
+}
+
+// commitmentSum accumulates the sum of digit commitments, each multiplied by
+// the power of the radix corresponding to its digit.
+type commitmentSum struct {
+	sum       Point
+	radix     Scalar
+	currPower Scalar
+}
+
+func newCommitmentSum(curve Curve, radix uint64) *commitmentSum {
+	return &commitmentSum{
+		radix:     curve.ScalarFromInt(uint32(radix)),
+		currPower: curve.ScalarFromInt(1),
+	}
+}
+
+func (s *commitmentSum) add(c Point) {
+	if s.sum == nil {
+		s.sum = c.Copy()
+	} else {
+		s.sum = s.sum.Add(c.ScalarMul(s.currPower))
+	}
+
+	s.currPower = s.currPower.Mul(s.radix)
+}
+
+func (s *commitmentSum) equals(point Point) bool {
+	return s.sum != nil && s.sum.Equals(point)
+}
+
+// remainder returns the commitment which, when added as the next digit,
+// makes the sum equal to the given point.
+func (s *commitmentSum) remainder(point Point) Point {
+	if s.sum == nil {
+		return point.Copy()
+	}
+
+	return point.Sub(s.sum).ScalarMul(s.currPower.Inverse())
 }
 
 // generate commitments to x for a curve.
-// x is expressed as bits b_0 ... b_n where n == bits.
-func generateCommitments(curve Curve, x []byte, bits uint64) ([]commitment, error) {
+// x is expressed as digits d_0 ... d_m in the given radix, where x has
+// the given number of bits. The blinders r_i are chosen such that
+// sum(r_i * radix^i) equals the given blinder, or zero if it's nil.
+func generateCommitments(curve Curve, x []byte, bits, radix uint64, blinder Scalar) ([]commitment, error) {
+	return generateCommitmentsWithBase(curve, curve.AltBasePoint(), x, bits, radix, blinder)
+}
+
+// generateCommitmentsWithBase generates commitments to x as generateCommitments
+// does, with the blinders multiplied by the given base point rather than the
+// curve's alternate base point.
+func generateCommitmentsWithBase(curve Curve, base Point, x []byte, bits, radix uint64, blinder Scalar) ([]commitment, error) {
+	n := numDigits(bits, radix)
+
 	// make n blinders
-	blinders := make([]Scalar, bits)
-	commitments := make([]commitment, bits)
+	blinders := make([]Scalar, n)
+	commitments := make([]commitment, n)
 
-	two := curve.ScalarFromInt(2)
-	currPowerOfTwo := curve.ScalarFromInt(1)
+	radixScalar := curve.ScalarFromInt(uint32(radix))
+	currPower := curve.ScalarFromInt(1)
 
 	sum := curve.ScalarFromInt(0)
+	if blinder == nil {
+		blinder = curve.ScalarFromInt(0)
+	}
 
-	for i := uint64(0); i < bits; i++ {
-		if i == bits-1 {
-			// (2^(n-1))^(-1)
-			currPowerOfTwoInv := currPowerOfTwo.Inverse()
+	for i := uint64(0); i < n; i++ {
+		if i == n-1 {
+			// (radix^(n-1))^(-1)
+			currPowerInv := currPower.Inverse()
 
 			// set r_(n-1)
-			blinders[i] = sum.Negate().Mul(currPowerOfTwoInv)
+			blinders[i] = blinder.Sub(sum).Mul(currPowerInv)
 
 			// sanity check
-			lastBlinderTimesPowerOfTwo := blinders[i].Mul(currPowerOfTwo)
-			sum = sum.Add(lastBlinderTimesPowerOfTwo)
-			if !sum.IsZero() {
-				panic("sum of blinders is not zero")
+			lastBlinderTimesPower := blinders[i].Mul(currPower)
+			sum = sum.Add(lastBlinderTimesPower)
+			if !sum.Eq(blinder) {
+				panic("sum of blinders is not equal to the blinder")
 			}
 		} else {
 			blinders[i] = curve.NewRandomScalar()
 
-			// r_i * 2^i
-			blinderTimesPowerOfTwo := blinders[i].Mul(currPowerOfTwo)
+			// r_i * radix^i
+			blinderTimesPower := blinders[i].Mul(currPower)
 
-			// sum(r_i * 2^i)
-			sum = sum.Add(blinderTimesPowerOfTwo)
+			// sum(r_i * radix^i)
+			sum = sum.Add(blinderTimesPower)
 
-			// set 2^(i+1) for next iteration
-			currPowerOfTwo = currPowerOfTwo.Mul(two)
-			if currPowerOfTwo.IsZero() {
-				panic("power of two should not be zero")
+			// set radix^(i+1) for next iteration
+			currPower = currPower.Mul(radixScalar)
+			if currPower.IsZero() {
+				panic("power of radix should not be zero")
 			}
 		}
 
-		if blinders[i].IsZero() {
+		// a single digit has the same blinder as the whole witness,
+		// which may be zero
+		if blinders[i].IsZero() && n > 1 {
 			panic(fmt.Sprintf("blinder %d is zero", i))
 		}
 
 		// generate commitment
-		// b_i * G' + r_i * G
-		b := curve.ScalarFromInt(uint32(getBit(x, i)))
-		bG := curve.ScalarBaseMul(b)
-		rG := curve.ScalarMul(blinders[i], curve.AltBasePoint())
-		c := bG.Add(rG)
+		// d_i * G' + r_i * G
+		digit := getDigit(x, bits, radix, i)
+		d := curve.ScalarFromInt(uint32(digit))
+		dG := curve.ScalarBaseMul(d)
+		rG := curve.ScalarMul(blinders[i], base)
+		c := dG.Add(rG)
 		if c.IsZero() {
 			panic("commitment should not be zero")
 		}
 
 		// sanity check, can remove later
-		if getBit(x, i) == 0 {
+		if digit == 0 {
 			if !c.Equals(rG) {
-				panic("commitment should be rG if bit isn't set")
+				panic("commitment should be rG if digit is zero")
 			}
 		}
 
@@ -239,106 +426,197 @@ func generateCommitments(curve Curve, x []byte, bits uint64) ([]commitment, erro
 	return commitments, nil
 }
 
+// generateRingSignature generates a ring signature over the ring members
+// C - j*G for j in [0, ringSize) on every curve, where the commitments
+// commit to the given digit.
+//
+// The ring signature is the interactive ring proof of Prover, with the
+// challenge which closes the ring derived by hashing the last ring member's
+// R, as for the other members.
 func generateRingSignature(
-	curveA, curveB Curve,
-	x byte,
-	commitmentA, commitmentB commitment,
+	curves []Curve,
+	version byte,
+	transcript []byte,
+	digit, ringSize uint64,
+	commitments []commitment,
 ) (*ringSignature, error) {
-	j, k := curveA.NewRandomScalar(), curveB.NewRandomScalar()
-
-	eA, err := hashToScalar(
-		curveA,
-		commitmentA.commitment,
-		commitmentB.commitment,
-		curveA.ScalarMul(j, curveA.AltBasePoint()),
-		curveB.ScalarMul(k, curveB.AltBasePoint()),
-	)
+	rp, err := newRingProver(curves, version, transcript, digit, ringSize, commitments)
 	if err != nil {
 		return nil, err
 	}
 
-	eB, err := hashToScalar(
-		curveB,
-		commitmentA.commitment,
-		commitmentB.commitment,
-		curveA.ScalarMul(j, curveA.AltBasePoint()),
-		curveB.ScalarMul(k, curveB.AltBasePoint()),
-	)
+	e, err := ringChallenges(curves, version, transcript, rp.points, rp.R)
 	if err != nil {
 		return nil, err
 	}
 
-	switch x {
-	case 0:
-		a0, b0 := curveA.NewRandomScalar(), curveB.NewRandomScalar()
+	return rp.respond(curves, e)
+}
 
-		commitmentAMinusOne := commitmentA.commitment.Sub(curveA.BasePoint())
-		commitmentBMinusOne := commitmentB.commitment.Sub(curveB.BasePoint())
+// ringProver generates a ring signature in two steps. First, it commits to
+// the R of the last ring member on each curve, starting the ring at the
+// member after the one it knows the secret for. Then, given the challenge of
+// the first member, it completes the ring.
+type ringProver struct {
+	version     byte
+	transcript  []byte
+	digit       uint64
+	ringSize    uint64
+	commitments []commitment
+	// points contains the digit commitment on each curve.
+	points []Point
+	nonces []Scalar
+	s      [][]Scalar
+	// R contains the R of the last ring member on each curve once committed.
+	R []Point
+}
 
-		ecA := commitmentAMinusOne.ScalarMul(eA)
-		ecB := commitmentBMinusOne.ScalarMul(eB)
-		A0 := curveA.ScalarMul(a0, curveA.AltBasePoint())
-		B0 := curveB.ScalarMul(b0, curveB.AltBasePoint())
+func newRingProver(
+	curves []Curve,
+	version byte,
+	transcript []byte,
+	digit, ringSize uint64,
+	commitments []commitment,
+) (*ringProver, error) {
+	if digit >= ringSize {
+		return nil, fmt.Errorf("digit must be less than %d", ringSize)
+	}
 
-		eA0, err := hashToScalar(curveA, commitmentA.commitment, commitmentB.commitment,
-			A0.Sub(ecA), B0.Sub(ecB))
+	rp := &ringProver{
+		version:     version,
+		transcript:  transcript,
+		digit:       digit,
+		ringSize:    ringSize,
+		commitments: commitments,
+		points:      make([]Point, len(curves)),
+		nonces:      make([]Scalar, len(curves)),
+		s:           make([][]Scalar, len(curves)),
+		R:           make([]Point, len(curves)),
+	}
+	for c, curve := range curves {
+		rp.points[c] = commitments[c].commitment
+		rp.nonces[c] = curve.NewRandomScalar()
+		rp.s[c] = make([]Scalar, ringSize)
+		rp.R[c] = curve.ScalarMul(rp.nonces[c], curve.AltBasePoint())
+	}
+
+	// simulate the members after the one we know the secret for
+	for i := digit + 1; i < ringSize; i++ {
+		e, err := ringChallenges(curves, version, transcript, rp.points, rp.R)
 		if err != nil {
 			return nil, err
 		}
 
-		eB0, err := hashToScalar(curveB, commitmentA.commitment, commitmentB.commitment,
-			A0.Sub(ecA), B0.Sub(ecB))
+		rp.simulate(curves, i, e)
+	}
+
+	return rp, nil
+}
+
+// simulate sets R to that of ring member i with a random response and the
+// given challenge.
+func (rp *ringProver) simulate(curves []Curve, i uint64, e *ringChallenge) {
+	for c, curve := range curves {
+		rp.s[c][i] = curve.NewRandomScalar()
+		member := ringMember(curve, rp.points[c], i)
+		ec := member.ScalarMul(e.e[c])
+		rp.R[c] = curve.ScalarMul(rp.s[c][i], curve.AltBasePoint()).Sub(ec)
+	}
+}
+
+// respond completes the ring given the challenge of the first ring member,
+// simulating the members before the one we know the secret for.
+func (rp *ringProver) respond(curves []Curve, e0 *ringChallenge) (*ringSignature, error) {
+	e := e0
+	for i := uint64(0); i < rp.digit; i++ {
+		rp.simulate(curves, i, e)
+
+		var err error
+		e, err = ringChallenges(curves, rp.version, rp.transcript, rp.points, rp.R)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	// close the ring
+	for c := range curves {
+		rp.s[c][rp.digit] = rp.nonces[c].Add(e.e[c].Mul(rp.commitments[c].blinder))
+	}
+
+	return &ringSignature{
+		e:    e0.e,
+		seed: e0.seed,
+		s:    rp.s,
+	}, nil
+}
+
+// ringMember returns the i'th ring member, ie. C - i*G.
+func ringMember(curve Curve, commitment Point, i uint64) Point {
+	if i == 0 {
+		return commitment
+	}
+
+	return commitment.Sub(curve.ScalarBaseMul(curve.ScalarFromInt(uint32(i))))
+}
+
+// ringChallenges returns the challenges on each curve for the next ring member,
+// given the digit commitments and the next ring member's R on each curve.
+// For version 1 proofs, each challenge is hashed to a scalar on its curve
+// directly. For compact proofs, the elements are hashed to a single seed,
+// which all challenges are derived from. The transcript, if any, is prepended
+// to the elements, binding the ring to the other statements it's proven with.
+func ringChallenges(curves []Curve, version byte, transcript []byte, commitments, R []Point) (*ringChallenge, error) {
+	elements := make([]interface{}, 0, len(commitments)+len(R))
+	for _, c := range commitments {
+		elements = append(elements, c)
+	}
+	for _, r := range R {
+		elements = append(elements, r)
+	}
 
-		a1 := j.Add(eA0.Mul(commitmentA.blinder))
-		b1 := k.Add(eB0.Mul(commitmentB.blinder))
-		return &ringSignature{
-			eCurveA: eA0,
-			eCurveB: eB0,
-			a0:      a0,
-			a1:      a1,
-			b0:      b0,
-			b1:      b1,
-		}, nil
-	case 1:
-		a1, b1 := curveA.NewRandomScalar(), curveB.NewRandomScalar()
-
-		ecA := commitmentA.commitment.ScalarMul(eA)
-		ecB := commitmentB.commitment.ScalarMul(eB)
-		A0 := curveA.ScalarMul(a1, curveA.AltBasePoint())
-		B0 := curveB.ScalarMul(b1, curveB.AltBasePoint())
-
-		eA1, err := hashToScalar(curveA, commitmentA.commitment, commitmentB.commitment,
-			A0.Sub(ecA), B0.Sub(ecB))
+	preimage, err := encodeElements(elements...)
+	if err != nil {
+		return nil, err
+	}
+	preimage = append(append([]byte{}, transcript...), preimage...)
+
+	if version == proofVersion2 {
+		seed := sha3.Sum256(preimage)
+		return challengesFromSeed(curves, seed[:])
+	}
+
+	e := make([]Scalar, len(curves))
+	for c, curve := range curves {
+		e[c], err = curve.HashToScalar(preimage)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	return &ringChallenge{
+		e: e,
+	}, nil
+}
 
-		eB1, err := hashToScalar(curveB, commitmentA.commitment, commitmentB.commitment,
-			A0.Sub(ecA), B0.Sub(ecB))
+// challengesFromSeed derives the challenges on each curve from a seed.
+func challengesFromSeed(curves []Curve, seed []byte) (*ringChallenge, error) {
+	e := make([]Scalar, len(curves))
+	for c, curve := range curves {
+		var err error
+		e[c], err = curve.HashToScalar(seed)
 		if err != nil {
 			return nil, err
 		}
-
-		a0 := j.Add(eA1.Mul(commitmentA.blinder))
-		b0 := k.Add(eB1.Mul(commitmentB.blinder))
-
-		return &ringSignature{
-			eCurveA: eA,
-			eCurveB: eB,
-			a0:      a0,
-			a1:      a1,
-			b0:      b0,
-			b1:      b1,
-		}, nil
-	default:
-		return nil, errors.New("input byte must be 0 or 1")
 	}
+
+	return &ringChallenge{
+		e:    e,
+		seed: seed,
+	}, nil
 }
 
-func hashToScalar(curve Curve, elements ...interface{}) (Scalar, error) {
+// encodeElements concatenates the encodings of the given scalars and points.
+func encodeElements(elements ...interface{}) ([]byte, error) {
 	preimage := []byte{}
 
 	for _, e := range elements {
@@ -354,7 +632,7 @@ func hashToScalar(curve Curve, elements ...interface{}) (Scalar, error) {
 		}
 	}
 
-	return curve.HashToScalar(preimage)
+	return preimage, nil
 }
 
 func min(a, b uint64) uint64 {
@@ -365,16 +643,29 @@ func min(a, b uint64) uint64 {
 	return b
 }
 
-// generateRandomBits generates up to 256 random bits.
-func generateRandomBits(bits uint64) ([32]byte, error) {
-	x := [32]byte{}
-	_, err := rand.Read(x[:])
+// minBitSize returns the minimum BitSize of the given curves.
+func minBitSize(curves []Curve) uint64 {
+	bits := curves[0].BitSize()
+	for _, curve := range curves[1:] {
+		bits = min(bits, curve.BitSize())
+	}
+
+	return bits
+}
+
+// generateRandomBits generates the given number of random bits, returned in
+// little-endian as ceil(bits/8) bytes.
+func generateRandomBits(bits uint64) ([]byte, error) {
+	x := make([]byte, (bits+7)/8)
+	_, err := rand.Read(x)
 	if err != nil {
-		return x, err
+		return nil, err
+	}
+
+	for i := bits; i < uint64(len(x))*8; i++ {
+		x[i/8] &^= 1 << (i % 8)
 	}
 
-	toClear := 256 - bits
-	x[31] &= 0xff >> toClear
 	return x, nil
 }
 
@@ -382,3 +673,51 @@ func generateRandomBits(bits uint64) ([32]byte, error) {
 func getBit(x []byte, i uint64) byte {
 	return (x[i/8] >> (i % 8)) & 1
 }
+
+// log2 returns the base-2 logarithm of the radix, ie. the number of bits
+// per digit. The radix must be a power of two.
+func log2(radix uint64) uint64 {
+	var w uint64
+	for radix > 1 {
+		radix >>= 1
+		w++
+	}
+
+	return w
+}
+
+// numDigits returns the number of digits of a witness with the given
+// number of bits when expressed in the given radix.
+func numDigits(bits, radix uint64) uint64 {
+	w := log2(radix)
+	return (bits + w - 1) / w
+}
+
+// digitWidth returns the number of bits in the digit at index i.
+// All digits are log2(radix) bits wide, except possibly the most significant
+// digit, which holds the remaining bits.
+func digitWidth(bits, radix, i uint64) uint64 {
+	w := log2(radix)
+	if (i+1)*w > bits {
+		return bits - i*w
+	}
+
+	return w
+}
+
+// digitRingSize returns the number of possible values of the digit at index i,
+// which is the size of its ring signature.
+func digitRingSize(bits, radix, i uint64) uint64 {
+	return 1 << digitWidth(bits, radix, i)
+}
+
+// getDigit returns the digit at the given index (in little endian)
+func getDigit(x []byte, bits, radix, i uint64) uint64 {
+	offset := i * log2(radix)
+	var digit uint64
+	for j := uint64(0); j < digitWidth(bits, radix, i); j++ {
+		digit |= uint64(getBit(x, offset+j)) << j
+	}
+
+	return digit
+}
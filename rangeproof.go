@@ -0,0 +1,161 @@
+package dleq
+
+import (
+	"errors"
+
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// RangeProof proves that a Pedersen commitment x*G + r*H, or a public key x*G,
+// on a single curve hides a value x in [0, 2^n), where n is the bit length set
+// with WithBits, or the bit size of the curve by default.
+//
+// The digit commitments only prove that the commitment is x*G + r*H for some
+// known r, so a public key proof also contains a signature proving knowledge of
+// the discrete logarithm of the public key, which ensures r is zero.
+type RangeProof struct {
+	Commitment Point
+	digitProofs
+	// signature is only set for public key proofs.
+	signature *signature
+}
+
+// NewRangeProof returns a new proof that the Pedersen commitment x*G + r*H
+// hides a value in [0, 2^n), where H is the curve's AltBasePoint and r is the
+// given blinder. The witness x must be in little-endian.
+func NewRangeProof(curve Curve, x []byte, blinder Scalar, opts ...Option) (*RangeProof, error) {
+	if blinder == nil {
+		return nil, errors.New("blinder must not be nil")
+	}
+
+	return newRangeProof(curve, x, blinder, opts)
+}
+
+// NewPublicKeyRangeProof returns a new proof that the public key x*G has a
+// discrete logarithm in [0, 2^n). The witness x must be in little-endian.
+func NewPublicKeyRangeProof(curve Curve, x []byte, opts ...Option) (*RangeProof, error) {
+	return newRangeProof(curve, x, nil, opts)
+}
+
+func newRangeProof(curve Curve, x []byte, blinder Scalar, opts []Option) (*RangeProof, error) {
+	o, err := newOptions(opts, curve.BitSize())
+	if err != nil {
+		return nil, err
+	}
+	if len(o.signatureSchemes) != 0 {
+		return nil, errSignatureSchemes
+	}
+
+	x, err = normalizeWitness(x, o.bits)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		commitment Point
+		blinders   []Scalar
+	)
+	if blinder == nil {
+		commitment = curve.ScalarBaseMul(scalarFromWitness(curve, x))
+	} else {
+		commitment = PedersenCommitment(curve, x, blinder)
+		blinders = []Scalar{blinder}
+	}
+
+	curves := []Curve{curve}
+	digits, err := newDigitProofs(curves, x, o, blinders, []Point{commitment})
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &RangeProof{
+		Commitment:  commitment,
+		digitProofs: *digits,
+	}
+
+	if blinder == nil {
+		sig, err := curve.Sign(scalarFromWitness(curve, x), commitment)
+		if err != nil {
+			return nil, err
+		}
+
+		proof.signature = &signature{
+			inner: sig,
+		}
+	}
+
+	return proof, nil
+}
+
+// Verify verifies that the proof's commitment hides a value in [0, 2^n).
+// A public key proof is also a valid proof for a commitment with a zero blinder.
+// By default, the proof must be for the full bit size of the curve; see WithMinBits.
+func (p *RangeProof) Verify(curve Curve, opts ...VerifyOption) error {
+	if p.Commitment == nil {
+		return errInvalidNumCommitments
+	}
+
+	curves := []Curve{curve}
+	err := p.digitProofs.checkParameters(curves, opts)
+	if err != nil {
+		return err
+	}
+
+	err = p.digitProofs.verify(curves, []Point{p.Commitment})
+	if err != nil {
+		return err
+	}
+
+	if p.signature != nil {
+		return verifySignatures(curves, []Point{p.Commitment}, []signature{*p.signature})
+	}
+
+	return nil
+}
+
+// VerifyPublicKey verifies that the proof's commitment is a public key whose
+// discrete logarithm is in [0, 2^n).
+// By default, the proof must be for the full bit size of the curve; see WithMinBits.
+func (p *RangeProof) VerifyPublicKey(curve Curve, opts ...VerifyOption) error {
+	if p.signature == nil {
+		return errors.New("range proof is not for a public key")
+	}
+
+	return p.Verify(curve, opts...)
+}
+
+// Serialize encodes the proof.
+//
+// The encoding is the same as that of a Proof on a single curve. Proofs for a
+// commitment have a zero-length signature.
+func (p *RangeProof) Serialize() []byte {
+	b := p.digitProofs.encode([]Point{p.Commitment})
+	if p.signature == nil {
+		return append(b, 0)
+	}
+
+	// WARN: this assumes the signature length is less than 256.
+	b = append(b, byte(len(p.signature.inner)))
+	return append(b, p.signature.inner...)
+}
+
+// Deserialize decodes the proof for the given curve.
+func (p *RangeProof) Deserialize(curve types.Curve, in []byte) error {
+	d := newDecoder(in)
+	_, commitments, err := p.digitProofs.decode(d, []types.Curve{curve}, curve.BitSize(), len(in))
+	if err != nil {
+		return err
+	}
+
+	sig, err := d.readSignature()
+	if err != nil {
+		return err
+	}
+
+	p.Commitment = commitments[0]
+	p.signature = nil
+	if len(sig.inner) != 0 {
+		p.signature = &sig
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package dleq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+)
+
+func TestRangeProof(t *testing.T) {
+	curve := secp256k1.NewCurve()
+	x, err := generateRandomBits(64)
+	require.NoError(t, err)
+	r := curve.NewRandomScalar()
+
+	proof, err := NewRangeProof(curve, x, r, WithBits(64), WithRadix(4))
+	require.NoError(t, err)
+	require.True(t, PedersenCommitment(curve, x, r).Equals(proof.Commitment))
+	err = proof.Verify(curve, WithMinBits(64))
+	require.NoError(t, err)
+	err = proof.Verify(curve)
+	require.Error(t, err)
+	err = proof.VerifyPublicKey(curve, WithMinBits(64))
+	require.Error(t, err)
+
+	ser := proof.Serialize()
+	deser := new(RangeProof)
+	err = deser.Deserialize(curve, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = deser.Verify(curve, WithMinBits(64))
+	require.NoError(t, err)
+
+	deser.Commitment = deser.Commitment.Add(curve.BasePoint())
+	err = deser.Verify(curve, WithMinBits(64))
+	require.Error(t, err)
+
+	// the value must be in range
+	x = append(x, 1)
+	_, err = NewRangeProof(curve, x, r, WithBits(64))
+	require.Error(t, err)
+}
+
+func TestRangeProof_PublicKey(t *testing.T) {
+	curve := ed25519.NewCurve()
+	x, err := generateRandomBits(32)
+	require.NoError(t, err)
+
+	proof, err := NewPublicKeyRangeProof(curve, x, WithBits(32), WithCompactEncoding())
+	require.NoError(t, err)
+	require.True(t, curve.ScalarBaseMul(curve.ScalarFromBytes(x)).Equals(proof.Commitment))
+	err = proof.VerifyPublicKey(curve, WithMinBits(32))
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(RangeProof)
+	err = deser.Deserialize(curve, ser)
+	require.NoError(t, err)
+	err = deser.VerifyPublicKey(curve, WithMinBits(32))
+	require.NoError(t, err)
+
+	// a commitment with a non-zero blinder isn't a public key
+	commitmentProof, err := NewRangeProof(curve, x, curve.NewRandomScalar(), WithBits(32))
+	require.NoError(t, err)
+	commitmentProof.signature = deser.signature
+	err = commitmentProof.VerifyPublicKey(curve, WithMinBits(32))
+	require.Error(t, err)
+}
@@ -0,0 +1,258 @@
+package secp256k1
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/athanorlabs/go-dleq/types"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// BIP340SignatureSize is the length of a BIP340 Schnorr signature.
+const BIP340SignatureSize = 64
+
+var _ types.SchemeSigner = &CurveImpl{}
+
+var (
+	errInvalidSecretKey = errors.New("secret key must be non-zero")
+	errPointAtInfinity  = errors.New("point is the point at infinity")
+)
+
+// taggedHash returns the BIP340 tagged hash of the concatenation of msgs,
+// ie. sha256(sha256(tag) || sha256(tag) || msgs...).
+func taggedHash(tag string, msgs ...[]byte) [32]byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// XOnlyPublicKey returns the 32-byte x-only encoding of p, ie. its x coordinate.
+// The parity of its y coordinate is dropped, so p and -p have the same encoding.
+func XOnlyPublicKey(p Point) ([]byte, error) {
+	pp, ok := p.(*PointImpl)
+	if !ok {
+		panic("invalid point; type is not *secp256k1.PointImpl")
+	}
+
+	if isInfinity(pp.inner) {
+		return nil, errPointAtInfinity
+	}
+
+	pp.inner.ToAffine()
+	return pp.inner.X.Bytes()[:], nil
+}
+
+// ParseXOnlyPublicKey decodes an x-only public key, returning the point with
+// the given x coordinate and an even y coordinate.
+func ParseXOnlyPublicKey(in []byte) (Point, error) {
+	if len(in) != 32 {
+		return nil, errors.New("x-only public key must be 32 bytes")
+	}
+
+	point := new(secp256k1.JacobianPoint)
+	if overflow := point.X.SetByteSlice(in); overflow {
+		return nil, errors.New("x coordinate is not less than the field size")
+	}
+
+	if !secp256k1.DecompressY(&point.X, false, &point.Y) {
+		return nil, errors.New("x coordinate is not on the curve")
+	}
+
+	point.Y.Normalize()
+	point.Z.SetInt(1)
+	return &PointImpl{
+		inner: point,
+	}, nil
+}
+
+// SignBIP340 returns the BIP340 Schnorr signature of msg by the secret key s,
+// whose public key is the x-only encoding of s*G.
+// auxRand is the 32 bytes of auxiliary randomness; if it's nil, it's read from
+// crypto/rand.
+func SignBIP340(s Scalar, msg, auxRand []byte) ([]byte, error) {
+	ss, ok := s.(*ScalarImpl)
+	if !ok {
+		panic("invalid scalar; type is not *secp256k1.ScalarImpl")
+	}
+
+	if ss.inner.IsZero() {
+		return nil, errInvalidSecretKey
+	}
+
+	if auxRand == nil {
+		auxRand = make([]byte, 32)
+		_, err := rand.Read(auxRand)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(auxRand) != 32 {
+		return nil, errors.New("auxiliary randomness must be 32 bytes")
+	}
+
+	// d = s if s*G has an even y coordinate, otherwise -s
+	d := new(secp256k1.ModNScalar).Set(ss.inner)
+	P := new(secp256k1.JacobianPoint)
+	secp256k1.ScalarBaseMultNonConst(d, P)
+	P.ToAffine()
+	if P.Y.IsOdd() {
+		d.Negate()
+	}
+	pBytes := P.X.Bytes()
+
+	dBytes := d.Bytes()
+	auxHash := taggedHash("BIP0340/aux", auxRand)
+	var t [32]byte
+	for i := range t {
+		t[i] = dBytes[i] ^ auxHash[i]
+	}
+
+	nonce := taggedHash("BIP0340/nonce", t[:], pBytes[:], msg)
+	k := new(secp256k1.ModNScalar)
+	k.SetBytes(&nonce)
+	if k.IsZero() {
+		return nil, errors.New("nonce is zero")
+	}
+
+	R := new(secp256k1.JacobianPoint)
+	secp256k1.ScalarBaseMultNonConst(k, R)
+	R.ToAffine()
+	if R.Y.IsOdd() {
+		k.Negate()
+	}
+	rBytes := R.X.Bytes()
+
+	e := bip340Challenge(rBytes[:], pBytes[:], msg)
+
+	// s = k + e*d
+	sig := make([]byte, BIP340SignatureSize)
+	copy(sig, rBytes[:])
+	sigS := new(secp256k1.ModNScalar).Mul2(e, d).Add(k)
+	sigS.PutBytesUnchecked(sig[32:])
+
+	if !VerifyBIP340(pBytes[:], msg, sig) {
+		return nil, errors.New("failed to verify generated signature")
+	}
+
+	return sig, nil
+}
+
+// VerifyBIP340 verifies the BIP340 Schnorr signature of msg by the x-only
+// public key pubkey.
+func VerifyBIP340(pubkey, msg, sig []byte) bool {
+	if len(sig) != BIP340SignatureSize {
+		return false
+	}
+
+	pub, err := ParseXOnlyPublicKey(pubkey)
+	if err != nil {
+		return false
+	}
+	P := pub.(*PointImpl).inner
+
+	var r secp256k1.FieldVal
+	if overflow := r.SetByteSlice(sig[:32]); overflow {
+		return false
+	}
+
+	var s secp256k1.ModNScalar
+	if overflow := s.SetByteSlice(sig[32:]); overflow {
+		return false
+	}
+
+	e := bip340Challenge(sig[:32], pubkey, msg)
+
+	// R = s*G - e*P
+	var sG, eP, R secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&s, &sG)
+	secp256k1.ScalarMultNonConst(e.Negate(), P, &eP)
+	secp256k1.AddNonConst(&sG, &eP, &R)
+	if isInfinity(&R) {
+		return false
+	}
+
+	R.ToAffine()
+	return !R.Y.IsOdd() && R.X.Equals(&r)
+}
+
+// bip340Challenge returns the challenge hash of a signature with the encoded
+// nonce point r by the x-only public key p, reduced modulo the curve order.
+func bip340Challenge(r, p, msg []byte) *secp256k1.ModNScalar {
+	h := taggedHash("BIP0340/challenge", r, p, msg)
+	e := new(secp256k1.ModNScalar)
+	e.SetBytes(&h)
+	return e
+}
+
+// BIP340Challenge returns the challenge of a BIP340 signature with the nonce
+// point R by the public key P, using the x-only encodings of both points.
+func BIP340Challenge(R, P Point, msg []byte) (Scalar, error) {
+	r, err := XOnlyPublicKey(R)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := XOnlyPublicKey(P)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScalarImpl{
+		inner: bip340Challenge(r, p, msg),
+	}, nil
+}
+
+func isInfinity(p *secp256k1.JacobianPoint) bool {
+	return (p.X.IsZero() && p.Y.IsZero()) || p.Z.IsZero()
+}
+
+// SupportsSignatureScheme returns whether the curve supports the given scheme,
+// which are the default (ECDSA) scheme and BIP340.
+func (*CurveImpl) SupportsSignatureScheme(scheme types.SignatureScheme) bool {
+	return scheme == types.DefaultSignatureScheme || scheme == types.BIP340
+}
+
+// SignWithScheme signs the encoded point `p` with the private key `s` using the
+// given signature scheme. BIP340 signatures are of sha256(p), as for Sign.
+func (c *CurveImpl) SignWithScheme(scheme types.SignatureScheme, s Scalar, p Point) ([]byte, error) {
+	switch scheme {
+	case types.DefaultSignatureScheme:
+		return c.Sign(s, p)
+	case types.BIP340:
+		hash := sha256.Sum256(p.Encode())
+		return SignBIP340(s, hash[:], nil)
+	default:
+		return nil, errors.New("unsupported signature scheme")
+	}
+}
+
+// VerifyWithScheme verifies a signature generated by SignWithScheme.
+// For BIP340, the public key is the x-only encoding of pubkey, so a signature
+// by the secret key of -pubkey is also valid.
+func (c *CurveImpl) VerifyWithScheme(scheme types.SignatureScheme, pubkey, msgPoint Point, sig []byte) bool {
+	switch scheme {
+	case types.DefaultSignatureScheme:
+		return c.Verify(pubkey, msgPoint, sig)
+	case types.BIP340:
+		pub, err := XOnlyPublicKey(pubkey)
+		if err != nil {
+			return false
+		}
+
+		hash := sha256.Sum256(msgPoint.Encode())
+		return VerifyBIP340(pub, hash[:], sig)
+	default:
+		return false
+	}
+}
@@ -81,6 +81,17 @@ func (*CurveImpl) CompressedPointSize() int {
 	return 33
 }
 
+var _ types.Orderer = &CurveImpl{}
+
+// Order returns the order of the curve's base point.
+func (c *CurveImpl) Order() *big.Int {
+	return new(big.Int).Set(c.order)
+}
+
+func (*CurveImpl) ScalarSize() int {
+	return 32
+}
+
 func (*CurveImpl) DecodeToPoint(in []byte) (Point, error) {
 	cp := make([]byte, len(in))
 	copy(cp, in)
@@ -142,10 +153,17 @@ func reverse(in [32]byte) [32]byte {
 }
 
 // ScalarFromBytes sets a Scalar from LE bytes.
-func (*CurveImpl) ScalarFromBytes(b [32]byte) Scalar {
+func (*CurveImpl) ScalarFromBytes(b []byte) Scalar {
+	if len(b) > 32 {
+		panic("scalar encoding must be at most 32 bytes")
+	}
+
+	var le [32]byte
+	copy(le[:], b)
+
 	s := new(secp256k1.ModNScalar)
 	// reverse bytes, since SetBytes takes BE bytes
-	in := reverse(b)
+	in := reverse(le)
 	s.SetBytes(&in)
 	return &ScalarImpl{
 		inner: s,
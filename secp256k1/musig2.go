@@ -0,0 +1,542 @@
+package secp256k1
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// This file implements MuSig2 multi-signatures as specified in BIP327. The
+// aggregate signature is a BIP340 signature by the x-only encoding of the
+// aggregate public key, eg. a Taproot output key.
+//
+// Public keys and public nonces use the 33-byte compressed encoding.
+
+const (
+	// MuSig2PublicNonceSize is the length of a MuSig2 public nonce, and of an
+	// aggregate nonce.
+	MuSig2PublicNonceSize = 66
+	// MuSig2SecretNonceSize is the length of a MuSig2 secret nonce.
+	MuSig2SecretNonceSize = 97
+	// MuSig2PartialSignatureSize is the length of a MuSig2 partial signature.
+	MuSig2PartialSignatureSize = 32
+)
+
+const compressedPointSize = 33
+
+var (
+	errNoPublicKeys           = errors.New("at least one public key is required")
+	errInvalidPublicNonce     = errors.New("invalid public nonce")
+	errInvalidAggregateNonce  = errors.New("invalid aggregate nonce")
+	errInvalidPartialSig      = errors.New("invalid partial signature")
+	errPublicKeyNotAggregated = errors.New("public key is not one of the aggregated keys")
+)
+
+// KeyAggContext is the result of aggregating public keys with MuSig2, with
+// any tweaks applied.
+type KeyAggContext struct {
+	pubkeys [][]byte
+	// secondKey is the first key which differs from the first one, whose
+	// coefficient is 1. It's nil if all keys are equal.
+	secondKey []byte
+	listHash  [32]byte
+	q         secp256k1.JacobianPoint
+	// gacc and tacc are the accumulated sign and tweak.
+	gacc, tacc secp256k1.ModNScalar
+}
+
+// SortPublicKeys returns the given compressed public keys sorted
+// lexicographically, as the canonical order for AggregatePublicKeys.
+func SortPublicKeys(pubkeys [][]byte) [][]byte {
+	sorted := make([][]byte, len(pubkeys))
+	copy(sorted, pubkeys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+	return sorted
+}
+
+// AggregatePublicKeys aggregates the given compressed public keys into a
+// MuSig2 aggregate key. The order of the keys matters; see SortPublicKeys.
+func AggregatePublicKeys(pubkeys [][]byte) (*KeyAggContext, error) {
+	if len(pubkeys) == 0 {
+		return nil, errNoPublicKeys
+	}
+
+	ctx := &KeyAggContext{
+		pubkeys: make([][]byte, len(pubkeys)),
+	}
+	for i, pk := range pubkeys {
+		ctx.pubkeys[i] = append([]byte{}, pk...)
+		if ctx.secondKey == nil && !bytes.Equal(pk, pubkeys[0]) {
+			ctx.secondKey = ctx.pubkeys[i]
+		}
+	}
+	ctx.listHash = taggedHash("KeyAgg list", ctx.pubkeys...)
+
+	for i, pk := range ctx.pubkeys {
+		P, err := parseCompressedPoint(pk)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %d: %w", i, err)
+		}
+
+		var aP, sum secp256k1.JacobianPoint
+		secp256k1.ScalarMultNonConst(ctx.coefficient(pk), P, &aP)
+		secp256k1.AddNonConst(&ctx.q, &aP, &sum)
+		ctx.q.Set(&sum)
+	}
+
+	if isInfinity(&ctx.q) {
+		return nil, errPointAtInfinity
+	}
+
+	ctx.q.ToAffine()
+	ctx.gacc.SetInt(1)
+	return ctx, nil
+}
+
+// coefficient returns the key aggregation coefficient of the public key pk.
+func (c *KeyAggContext) coefficient(pk []byte) *secp256k1.ModNScalar {
+	a := new(secp256k1.ModNScalar)
+	if c.secondKey != nil && bytes.Equal(pk, c.secondKey) {
+		return a.SetInt(1)
+	}
+
+	h := taggedHash("KeyAgg coefficient", c.listHash[:], pk)
+	a.SetBytes(&h)
+	return a
+}
+
+// sessionCoefficient returns the key aggregation coefficient of the public
+// key pk, which must be one of the aggregated keys.
+func (c *KeyAggContext) sessionCoefficient(pk []byte) (*secp256k1.ModNScalar, error) {
+	for _, key := range c.pubkeys {
+		if bytes.Equal(key, pk) {
+			return c.coefficient(pk), nil
+		}
+	}
+
+	return nil, errPublicKeyNotAggregated
+}
+
+// Coefficient returns the key aggregation coefficient a_i of the public key
+// pk, which must be one of the aggregated keys. Before any tweaks, the
+// aggregate public key is the sum of a_i*P_i over the aggregated keys P_i.
+func (c *KeyAggContext) Coefficient(pk []byte) (Scalar, error) {
+	a, err := c.sessionCoefficient(pk)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScalarImpl{
+		inner: a,
+	}, nil
+}
+
+// PublicKey returns the aggregate public key, with any tweaks applied.
+func (c *KeyAggContext) PublicKey() Point {
+	q := new(secp256k1.JacobianPoint)
+	q.Set(&c.q)
+	return &PointImpl{
+		inner: q,
+	}
+}
+
+// XOnlyPublicKey returns the x-only encoding of the aggregate public key,
+// which the aggregate signature is valid for.
+func (c *KeyAggContext) XOnlyPublicKey() []byte {
+	x := c.q.X.Bytes()
+	return x[:]
+}
+
+// ApplyTweak returns the context with the 32-byte tweak t added to the
+// aggregate public key Q. For an x-only tweak, the result is t*G plus the
+// point with Q's x coordinate and an even y coordinate, as for Taproot
+// output keys; otherwise it's Q + t*G.
+func (c *KeyAggContext) ApplyTweak(tweak []byte, xOnly bool) (*KeyAggContext, error) {
+	var t secp256k1.ModNScalar
+	if len(tweak) != 32 || t.SetByteSlice(tweak) {
+		return nil, errors.New("tweak must be 32 bytes and less than the curve order")
+	}
+
+	var g secp256k1.ModNScalar
+	g.SetInt(1)
+	if xOnly && c.q.Y.IsOdd() {
+		g.Negate()
+	}
+
+	// Q' = g*Q + t*G
+	var gQ, tG secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(&g, &c.q, &gQ)
+	secp256k1.ScalarBaseMultNonConst(&t, &tG)
+
+	tweaked := *c
+	secp256k1.AddNonConst(&gQ, &tG, &tweaked.q)
+	if isInfinity(&tweaked.q) {
+		return nil, errPointAtInfinity
+	}
+
+	tweaked.q.ToAffine()
+	tweaked.gacc.Mul2(&g, &c.gacc)
+	tweaked.tacc.Mul2(&g, &c.tacc).Add(&t)
+	return &tweaked, nil
+}
+
+// GenerateMuSig2Nonce generates a secret and public nonce for signing with
+// the compressed public key pubkey.
+//
+// The secret key, the x-only aggregate public key, the message and the extra
+// input are optional, and may be nil; they're mixed into the nonce as
+// additional protection against bad randomness. A nil message differs from an
+// empty one. The secret nonce must only be used for a single signature.
+func GenerateMuSig2Nonce(sk Scalar, pubkey, aggPubkey, msg, extraIn []byte) ([]byte, []byte, error) {
+	randBytes := make([]byte, 32)
+	_, err := rand.Read(randBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return musig2NonceGen(randBytes, sk, pubkey, aggPubkey, msg, extraIn)
+}
+
+// musig2NonceGen generates a nonce from the given 32 bytes of randomness.
+func musig2NonceGen(randBytes []byte, sk Scalar, pubkey, aggPubkey, msg, extraIn []byte) ([]byte, []byte, error) {
+	if len(pubkey) != compressedPointSize {
+		return nil, nil, errors.New("public key must be 33 bytes")
+	}
+
+	if aggPubkey != nil && len(aggPubkey) != 32 {
+		return nil, nil, errors.New("aggregate public key must be 32 bytes")
+	}
+
+	if sk != nil {
+		ss, ok := sk.(*ScalarImpl)
+		if !ok {
+			panic("invalid scalar; type is not *secp256k1.ScalarImpl")
+		}
+
+		skBytes := ss.inner.Bytes()
+		auxHash := taggedHash("MuSig/aux", randBytes)
+		randBytes = make([]byte, 32)
+		for i := range randBytes {
+			randBytes[i] = skBytes[i] ^ auxHash[i]
+		}
+	}
+
+	msgPrefixed := []byte{0}
+	if msg != nil {
+		msgPrefixed = make([]byte, 9, 9+len(msg))
+		msgPrefixed[0] = 1
+		binary.BigEndian.PutUint64(msgPrefixed[1:], uint64(len(msg)))
+		msgPrefixed = append(msgPrefixed, msg...)
+	}
+
+	var extraLen [4]byte
+	binary.BigEndian.PutUint32(extraLen[:], uint32(len(extraIn)))
+
+	secNonce := make([]byte, 0, MuSig2SecretNonceSize)
+	pubNonce := make([]byte, 0, MuSig2PublicNonceSize)
+	for i := byte(0); i < 2; i++ {
+		h := taggedHash("MuSig/nonce",
+			randBytes,
+			[]byte{byte(len(pubkey))}, pubkey,
+			[]byte{byte(len(aggPubkey))}, aggPubkey,
+			msgPrefixed,
+			extraLen[:], extraIn,
+			[]byte{i},
+		)
+
+		var k secp256k1.ModNScalar
+		k.SetBytes(&h)
+		if k.IsZero() {
+			return nil, nil, errors.New("nonce is zero")
+		}
+
+		var R secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(&k, &R)
+		kBytes := k.Bytes()
+		secNonce = append(secNonce, kBytes[:]...)
+		pubNonce = append(pubNonce, encodeCompressed(&R)...)
+	}
+
+	secNonce = append(secNonce, pubkey...)
+	return secNonce, pubNonce, nil
+}
+
+// AggregateMuSig2Nonces aggregates the public nonces of all signers.
+func AggregateMuSig2Nonces(pubNonces [][]byte) ([]byte, error) {
+	if len(pubNonces) == 0 {
+		return nil, errors.New("at least one public nonce is required")
+	}
+
+	aggNonce := make([]byte, 0, MuSig2PublicNonceSize)
+	for j := 0; j < 2; j++ {
+		var R secp256k1.JacobianPoint
+		for i, pubNonce := range pubNonces {
+			if len(pubNonce) != MuSig2PublicNonceSize {
+				return nil, fmt.Errorf("public nonce %d: %w", i, errInvalidPublicNonce)
+			}
+
+			Ri, err := parseCompressedPoint(pubNonce[j*compressedPointSize : (j+1)*compressedPointSize])
+			if err != nil {
+				return nil, fmt.Errorf("public nonce %d: %w", i, errInvalidPublicNonce)
+			}
+
+			var sum secp256k1.JacobianPoint
+			secp256k1.AddNonConst(&R, Ri, &sum)
+			R.Set(&sum)
+		}
+
+		aggNonce = append(aggNonce, encodeCompressedExt(&R)...)
+	}
+
+	return aggNonce, nil
+}
+
+// MuSig2Session is a MuSig2 signing session for a message, given the
+// aggregate key and the aggregate nonce.
+type MuSig2Session struct {
+	keyAgg *KeyAggContext
+	// b is the nonce coefficient, and e is the challenge of the signature
+	// with the nonce point R.
+	b, e secp256k1.ModNScalar
+	r    secp256k1.JacobianPoint
+}
+
+// NewMuSig2Session returns a new session for signing msg with the aggregate
+// key, given the aggregate nonce of all signers.
+func NewMuSig2Session(keyAgg *KeyAggContext, aggNonce, msg []byte) (*MuSig2Session, error) {
+	if len(aggNonce) != MuSig2PublicNonceSize {
+		return nil, errInvalidAggregateNonce
+	}
+
+	qx := keyAgg.XOnlyPublicKey()
+	h := taggedHash("MuSig/noncecoef", aggNonce, qx, msg)
+	s := &MuSig2Session{
+		keyAgg: keyAgg,
+	}
+	s.b.SetBytes(&h)
+
+	var R1, R2 *secp256k1.JacobianPoint
+	for j, R := range []**secp256k1.JacobianPoint{&R1, &R2} {
+		var err error
+		*R, err = parseCompressedPointExt(aggNonce[j*compressedPointSize : (j+1)*compressedPointSize])
+		if err != nil {
+			return nil, errInvalidAggregateNonce
+		}
+	}
+
+	// R = R1 + b*R2, or G if it's the point at infinity
+	var bR2 secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(&s.b, R2, &bR2)
+	secp256k1.AddNonConst(R1, &bR2, &s.r)
+	if isInfinity(&s.r) {
+		var one secp256k1.ModNScalar
+		secp256k1.ScalarBaseMultNonConst(one.SetInt(1), &s.r)
+	}
+
+	s.r.ToAffine()
+	rx := s.r.X.Bytes()
+	s.e.Set(bip340Challenge(rx[:], qx, msg))
+	return s, nil
+}
+
+// keySign returns the sign of the aggregate key's secret key, ie. -gacc if
+// the aggregate key has an odd y coordinate, and gacc otherwise.
+func (s *MuSig2Session) keySign() *secp256k1.ModNScalar {
+	g := new(secp256k1.ModNScalar).Set(&s.keyAgg.gacc)
+	if s.keyAgg.q.Y.IsOdd() {
+		g.Negate()
+	}
+
+	return g
+}
+
+// Sign returns the partial signature of the session's message by the secret
+// key sk, using the secret nonce generated with GenerateMuSig2Nonce.
+// The secret nonce is zeroed, so that it can't be reused.
+func (s *MuSig2Session) Sign(secNonce []byte, sk Scalar) ([]byte, error) {
+	if len(secNonce) != MuSig2SecretNonceSize {
+		return nil, errors.New("secret nonce must be 97 bytes")
+	}
+
+	var k1, k2 secp256k1.ModNScalar
+	overflow1, overflow2 := k1.SetByteSlice(secNonce[:32]), k2.SetByteSlice(secNonce[32:64])
+	pubNonce := make([]byte, 0, MuSig2PublicNonceSize)
+	pubkey := append([]byte{}, secNonce[64:]...)
+	for i := range secNonce[:64] {
+		secNonce[i] = 0
+	}
+
+	if overflow1 || overflow2 || k1.IsZero() || k2.IsZero() {
+		return nil, errors.New("secret nonce is invalid or has already been used")
+	}
+
+	for _, k := range []*secp256k1.ModNScalar{&k1, &k2} {
+		var R secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(k, &R)
+		pubNonce = append(pubNonce, encodeCompressed(&R)...)
+		if s.r.Y.IsOdd() {
+			k.Negate()
+		}
+	}
+
+	ss, ok := sk.(*ScalarImpl)
+	if !ok {
+		panic("invalid scalar; type is not *secp256k1.ScalarImpl")
+	}
+
+	if ss.inner.IsZero() {
+		return nil, errInvalidSecretKey
+	}
+
+	var P secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(ss.inner, &P)
+	if !bytes.Equal(encodeCompressed(&P), pubkey) {
+		return nil, errors.New("secret key does not match the secret nonce's public key")
+	}
+
+	a, err := s.keyAgg.sessionCoefficient(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	// s = k1 + b*k2 + e*a*d, where d = g*gacc*sk
+	d := new(secp256k1.ModNScalar).Mul2(s.keySign(), ss.inner)
+	sig := new(secp256k1.ModNScalar).Mul2(&s.e, a).Mul(d)
+	sig.Add(&k1).Add(new(secp256k1.ModNScalar).Mul2(&s.b, &k2))
+
+	psig := sig.Bytes()
+	if !s.VerifyPartialSignature(psig[:], pubNonce, pubkey) {
+		return nil, errors.New("failed to verify generated partial signature")
+	}
+
+	return psig[:], nil
+}
+
+// VerifyPartialSignature verifies the partial signature of the session's
+// message by the signer with the given public nonce and compressed public key.
+func (s *MuSig2Session) VerifyPartialSignature(psig, pubNonce, pubkey []byte) bool {
+	var sig secp256k1.ModNScalar
+	if len(psig) != MuSig2PartialSignatureSize || sig.SetByteSlice(psig) {
+		return false
+	}
+
+	if len(pubNonce) != MuSig2PublicNonceSize {
+		return false
+	}
+
+	R1, err := parseCompressedPoint(pubNonce[:compressedPointSize])
+	if err != nil {
+		return false
+	}
+
+	R2, err := parseCompressedPoint(pubNonce[compressedPointSize:])
+	if err != nil {
+		return false
+	}
+
+	P, err := parseCompressedPoint(pubkey)
+	if err != nil {
+		return false
+	}
+
+	a, err := s.keyAgg.sessionCoefficient(pubkey)
+	if err != nil {
+		return false
+	}
+
+	// s*G = ±(R1 + b*R2) + e*a*g*gacc*P
+	var bR2, Re secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(&s.b, R2, &bR2)
+	secp256k1.AddNonConst(R1, &bR2, &Re)
+	if s.r.Y.IsOdd() {
+		Re.ToAffine()
+		Re.Y.Negate(1).Normalize()
+	}
+
+	var eP, rhs, lhs secp256k1.JacobianPoint
+	eag := new(secp256k1.ModNScalar).Mul2(&s.e, a).Mul(s.keySign())
+	secp256k1.ScalarMultNonConst(eag, P, &eP)
+	secp256k1.AddNonConst(&Re, &eP, &rhs)
+	secp256k1.ScalarBaseMultNonConst(&sig, &lhs)
+
+	lhs.ToAffine()
+	rhs.ToAffine()
+	return !isInfinity(&lhs) && lhs.X.Equals(&rhs.X) && lhs.Y.Equals(&rhs.Y)
+}
+
+// AggregatePartialSignatures aggregates the partial signatures of all signers
+// into a BIP340 signature by the aggregate key's x-only public key.
+// The partial signatures should have been verified with VerifyPartialSignature.
+func (s *MuSig2Session) AggregatePartialSignatures(psigs [][]byte) ([]byte, error) {
+	// s = sum(s_i) + e*g*tacc
+	sum := new(secp256k1.ModNScalar)
+	if s.keyAgg.q.Y.IsOdd() {
+		sum.NegateVal(&s.keyAgg.tacc)
+	} else {
+		sum.Set(&s.keyAgg.tacc)
+	}
+	sum.Mul(&s.e)
+
+	for i, psig := range psigs {
+		var si secp256k1.ModNScalar
+		if len(psig) != MuSig2PartialSignatureSize || si.SetByteSlice(psig) {
+			return nil, fmt.Errorf("partial signature %d: %w", i, errInvalidPartialSig)
+		}
+
+		sum.Add(&si)
+	}
+
+	sig := make([]byte, BIP340SignatureSize)
+	rx := s.r.X.Bytes()
+	copy(sig, rx[:])
+	sum.PutBytesUnchecked(sig[32:])
+	return sig, nil
+}
+
+// parseCompressedPoint decodes a 33-byte compressed point.
+func parseCompressedPoint(in []byte) (*secp256k1.JacobianPoint, error) {
+	if len(in) != compressedPointSize {
+		return nil, errors.New("compressed point must be 33 bytes")
+	}
+
+	pub, err := secp256k1.ParsePubKey(in)
+	if err != nil {
+		return nil, err
+	}
+
+	P := new(secp256k1.JacobianPoint)
+	pub.AsJacobian(P)
+	return P, nil
+}
+
+// parseCompressedPointExt decodes a 33-byte compressed point, where 33 zero
+// bytes encode the point at infinity.
+func parseCompressedPointExt(in []byte) (*secp256k1.JacobianPoint, error) {
+	if bytes.Equal(in, make([]byte, compressedPointSize)) {
+		return new(secp256k1.JacobianPoint), nil
+	}
+
+	return parseCompressedPoint(in)
+}
+
+func encodeCompressed(p *secp256k1.JacobianPoint) []byte {
+	p.ToAffine()
+	return secp256k1.NewPublicKey(&p.X, &p.Y).SerializeCompressed()
+}
+
+// encodeCompressedExt encodes p as a 33-byte compressed point, or 33 zero
+// bytes if it's the point at infinity.
+func encodeCompressedExt(p *secp256k1.JacobianPoint) []byte {
+	if isInfinity(p) {
+		return make([]byte, compressedPointSize)
+	}
+
+	return encodeCompressed(p)
+}
@@ -0,0 +1,445 @@
+package secp256k1
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The MuSig2 test vectors are from BIP327.
+
+func readMuSig2Vectors(t *testing.T, name string, v interface{}) {
+	b, err := os.ReadFile(filepath.Join("testdata", "musig2", name))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, v))
+}
+
+func selectHex(t *testing.T, values []string, indices []int) [][]byte {
+	out := make([][]byte, len(indices))
+	for i, idx := range indices {
+		out[i] = mustDecodeHex(t, values[idx])
+	}
+	return out
+}
+
+// tweakKeyAgg aggregates the keys and applies the tweaks.
+func tweakKeyAgg(t *testing.T, pubkeys, tweaks [][]byte, xOnly []bool) (*KeyAggContext, error) {
+	ctx, err := AggregatePublicKeys(pubkeys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, tweak := range tweaks {
+		ctx, err = ctx.ApplyTweak(tweak, xOnly[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ctx, nil
+}
+
+func scalarFromHex(t *testing.T, s string) Scalar {
+	b := mustDecodeHex(t, s)
+	le := make([]byte, len(b))
+	for i := range b {
+		le[i] = b[len(b)-1-i]
+	}
+	return NewCurve().ScalarFromBytes(le)
+}
+
+func TestMuSig2_KeySort(t *testing.T) {
+	var v struct {
+		Pubkeys       []string `json:"pubkeys"`
+		SortedPubkeys []string `json:"sorted_pubkeys"`
+	}
+	readMuSig2Vectors(t, "key_sort_vectors.json", &v)
+
+	pubkeys := selectHex(t, v.Pubkeys, []int{0, 1, 2, 3, 4})
+	sorted := selectHex(t, v.SortedPubkeys, []int{0, 1, 2, 3, 4})
+	require.Equal(t, sorted, SortPublicKeys(pubkeys))
+}
+
+func TestMuSig2_KeyAgg(t *testing.T) {
+	var v struct {
+		Pubkeys    []string `json:"pubkeys"`
+		Tweaks     []string `json:"tweaks"`
+		ValidCases []struct {
+			KeyIndices []int  `json:"key_indices"`
+			Expected   string `json:"expected"`
+		} `json:"valid_test_cases"`
+		ErrorCases []struct {
+			KeyIndices   []int  `json:"key_indices"`
+			TweakIndices []int  `json:"tweak_indices"`
+			IsXOnly      []bool `json:"is_xonly"`
+		} `json:"error_test_cases"`
+	}
+	readMuSig2Vectors(t, "key_agg_vectors.json", &v)
+
+	for i, tc := range v.ValidCases {
+		ctx, err := AggregatePublicKeys(selectHex(t, v.Pubkeys, tc.KeyIndices))
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, tc.Expected), ctx.XOnlyPublicKey(), i)
+
+		// the aggregate key is the sum of each key weighted by its coefficient
+		curve := NewCurve()
+		var q Point
+		for _, pk := range selectHex(t, v.Pubkeys, tc.KeyIndices) {
+			P, err := curve.DecodeToPoint(pk)
+			require.NoError(t, err, i)
+			a, err := ctx.Coefficient(pk)
+			require.NoError(t, err, i)
+			if q == nil {
+				q = P.ScalarMul(a)
+				continue
+			}
+			q = q.Add(P.ScalarMul(a))
+		}
+		require.True(t, q.Equals(ctx.PublicKey()), i)
+	}
+
+	ctx, err := AggregatePublicKeys(selectHex(t, v.Pubkeys, []int{0, 1}))
+	require.NoError(t, err)
+	_, err = ctx.Coefficient(mustDecodeHex(t, v.Pubkeys[2]))
+	require.ErrorIs(t, err, errPublicKeyNotAggregated)
+
+	for i, tc := range v.ErrorCases {
+		pubkeys := selectHex(t, v.Pubkeys, tc.KeyIndices)
+		tweaks := selectHex(t, v.Tweaks, tc.TweakIndices)
+		_, err := tweakKeyAgg(t, pubkeys, tweaks, tc.IsXOnly)
+		require.Error(t, err, i)
+	}
+}
+
+func TestMuSig2_NonceGen(t *testing.T) {
+	var v struct {
+		TestCases []struct {
+			Rand     string  `json:"rand_"`
+			Sk       *string `json:"sk"`
+			Pk       string  `json:"pk"`
+			AggPk    *string `json:"aggpk"`
+			Msg      *string `json:"msg"`
+			ExtraIn  *string `json:"extra_in"`
+			Expected string  `json:"expected"`
+		} `json:"test_cases"`
+	}
+	readMuSig2Vectors(t, "nonce_gen_vectors.json", &v)
+
+	optional := func(s *string) []byte {
+		if s == nil {
+			return nil
+		}
+		return mustDecodeHex(t, *s)
+	}
+
+	for i, tc := range v.TestCases {
+		var sk Scalar
+		if tc.Sk != nil {
+			sk = scalarFromHex(t, *tc.Sk)
+		}
+
+		secNonce, pubNonce, err := musig2NonceGen(
+			mustDecodeHex(t, tc.Rand),
+			sk,
+			mustDecodeHex(t, tc.Pk),
+			optional(tc.AggPk),
+			optional(tc.Msg),
+			optional(tc.ExtraIn),
+		)
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, tc.Expected), secNonce, i)
+		require.Len(t, pubNonce, MuSig2PublicNonceSize)
+	}
+}
+
+func TestMuSig2_NonceAgg(t *testing.T) {
+	var v struct {
+		PubNonces  []string `json:"pnonces"`
+		ValidCases []struct {
+			Indices  []int  `json:"pnonce_indices"`
+			Expected string `json:"expected"`
+		} `json:"valid_test_cases"`
+		ErrorCases []struct {
+			Indices []int `json:"pnonce_indices"`
+		} `json:"error_test_cases"`
+	}
+	readMuSig2Vectors(t, "nonce_agg_vectors.json", &v)
+
+	for i, tc := range v.ValidCases {
+		aggNonce, err := AggregateMuSig2Nonces(selectHex(t, v.PubNonces, tc.Indices))
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, tc.Expected), aggNonce, i)
+	}
+
+	for i, tc := range v.ErrorCases {
+		_, err := AggregateMuSig2Nonces(selectHex(t, v.PubNonces, tc.Indices))
+		require.Error(t, err, i)
+	}
+}
+
+func TestMuSig2_SignVerify(t *testing.T) {
+	var v struct {
+		Sk         string   `json:"sk"`
+		Pubkeys    []string `json:"pubkeys"`
+		SecNonces  []string `json:"secnonces"`
+		PubNonces  []string `json:"pnonces"`
+		AggNonces  []string `json:"aggnonces"`
+		Msgs       []string `json:"msgs"`
+		ValidCases []struct {
+			KeyIndices    []int  `json:"key_indices"`
+			NonceIndices  []int  `json:"nonce_indices"`
+			AggNonceIndex int    `json:"aggnonce_index"`
+			MsgIndex      int    `json:"msg_index"`
+			SignerIndex   int    `json:"signer_index"`
+			Expected      string `json:"expected"`
+		} `json:"valid_test_cases"`
+		SignErrorCases []struct {
+			KeyIndices    []int `json:"key_indices"`
+			AggNonceIndex int   `json:"aggnonce_index"`
+			MsgIndex      int   `json:"msg_index"`
+			SecNonceIndex int   `json:"secnonce_index"`
+		} `json:"sign_error_test_cases"`
+		VerifyFailCases []struct {
+			Sig          string `json:"sig"`
+			KeyIndices   []int  `json:"key_indices"`
+			NonceIndices []int  `json:"nonce_indices"`
+			MsgIndex     int    `json:"msg_index"`
+			SignerIndex  int    `json:"signer_index"`
+		} `json:"verify_fail_test_cases"`
+	}
+	readMuSig2Vectors(t, "sign_verify_vectors.json", &v)
+	sk := scalarFromHex(t, v.Sk)
+
+	for i, tc := range v.ValidCases {
+		pubkeys := selectHex(t, v.Pubkeys, tc.KeyIndices)
+		pubNonces := selectHex(t, v.PubNonces, tc.NonceIndices)
+		aggNonce, err := AggregateMuSig2Nonces(pubNonces)
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, v.AggNonces[tc.AggNonceIndex]), aggNonce, i)
+
+		keyAgg, err := AggregatePublicKeys(pubkeys)
+		require.NoError(t, err, i)
+		session, err := NewMuSig2Session(keyAgg, aggNonce, mustDecodeHex(t, v.Msgs[tc.MsgIndex]))
+		require.NoError(t, err, i)
+
+		psig, err := session.Sign(mustDecodeHex(t, v.SecNonces[0]), sk)
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, tc.Expected), psig, i)
+		require.True(t, session.VerifyPartialSignature(psig, pubNonces[tc.SignerIndex], pubkeys[tc.SignerIndex]), i)
+	}
+
+	for i, tc := range v.SignErrorCases {
+		keyAgg, err := AggregatePublicKeys(selectHex(t, v.Pubkeys, tc.KeyIndices))
+		if err != nil {
+			continue
+		}
+
+		session, err := NewMuSig2Session(keyAgg, mustDecodeHex(t, v.AggNonces[tc.AggNonceIndex]), mustDecodeHex(t, v.Msgs[tc.MsgIndex]))
+		if err != nil {
+			continue
+		}
+
+		_, err = session.Sign(mustDecodeHex(t, v.SecNonces[tc.SecNonceIndex]), sk)
+		require.Error(t, err, i)
+	}
+
+	for i, tc := range v.VerifyFailCases {
+		pubkeys := selectHex(t, v.Pubkeys, tc.KeyIndices)
+		pubNonces := selectHex(t, v.PubNonces, tc.NonceIndices)
+		aggNonce, err := AggregateMuSig2Nonces(pubNonces)
+		require.NoError(t, err, i)
+		keyAgg, err := AggregatePublicKeys(pubkeys)
+		require.NoError(t, err, i)
+		session, err := NewMuSig2Session(keyAgg, aggNonce, mustDecodeHex(t, v.Msgs[tc.MsgIndex]))
+		require.NoError(t, err, i)
+		require.False(t, session.VerifyPartialSignature(mustDecodeHex(t, tc.Sig), pubNonces[tc.SignerIndex], pubkeys[tc.SignerIndex]), i)
+	}
+}
+
+func TestMuSig2_SecretNonceReuse(t *testing.T) {
+	var v struct {
+		Sk        string   `json:"sk"`
+		Pubkeys   []string `json:"pubkeys"`
+		SecNonces []string `json:"secnonces"`
+		AggNonces []string `json:"aggnonces"`
+		Msgs      []string `json:"msgs"`
+	}
+	readMuSig2Vectors(t, "sign_verify_vectors.json", &v)
+
+	keyAgg, err := AggregatePublicKeys(selectHex(t, v.Pubkeys, []int{0, 1, 2}))
+	require.NoError(t, err)
+	session, err := NewMuSig2Session(keyAgg, mustDecodeHex(t, v.AggNonces[0]), mustDecodeHex(t, v.Msgs[0]))
+	require.NoError(t, err)
+
+	secNonce := mustDecodeHex(t, v.SecNonces[0])
+	_, err = session.Sign(secNonce, scalarFromHex(t, v.Sk))
+	require.NoError(t, err)
+	_, err = session.Sign(secNonce, scalarFromHex(t, v.Sk))
+	require.Error(t, err)
+}
+
+func TestMuSig2_Tweak(t *testing.T) {
+	var v struct {
+		Sk         string   `json:"sk"`
+		Pubkeys    []string `json:"pubkeys"`
+		SecNonce   string   `json:"secnonce"`
+		PubNonces  []string `json:"pnonces"`
+		AggNonce   string   `json:"aggnonce"`
+		Tweaks     []string `json:"tweaks"`
+		Msg        string   `json:"msg"`
+		ValidCases []struct {
+			KeyIndices   []int  `json:"key_indices"`
+			NonceIndices []int  `json:"nonce_indices"`
+			TweakIndices []int  `json:"tweak_indices"`
+			IsXOnly      []bool `json:"is_xonly"`
+			SignerIndex  int    `json:"signer_index"`
+			Expected     string `json:"expected"`
+		} `json:"valid_test_cases"`
+		ErrorCases []struct {
+			KeyIndices   []int  `json:"key_indices"`
+			TweakIndices []int  `json:"tweak_indices"`
+			IsXOnly      []bool `json:"is_xonly"`
+		} `json:"error_test_cases"`
+	}
+	readMuSig2Vectors(t, "tweak_vectors.json", &v)
+	sk := scalarFromHex(t, v.Sk)
+	msg := mustDecodeHex(t, v.Msg)
+
+	for i, tc := range v.ValidCases {
+		pubkeys := selectHex(t, v.Pubkeys, tc.KeyIndices)
+		pubNonces := selectHex(t, v.PubNonces, tc.NonceIndices)
+		aggNonce, err := AggregateMuSig2Nonces(pubNonces)
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, v.AggNonce), aggNonce, i)
+
+		keyAgg, err := tweakKeyAgg(t, pubkeys, selectHex(t, v.Tweaks, tc.TweakIndices), tc.IsXOnly)
+		require.NoError(t, err, i)
+		session, err := NewMuSig2Session(keyAgg, aggNonce, msg)
+		require.NoError(t, err, i)
+
+		psig, err := session.Sign(mustDecodeHex(t, v.SecNonce), sk)
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, tc.Expected), psig, i)
+		require.True(t, session.VerifyPartialSignature(psig, pubNonces[tc.SignerIndex], pubkeys[tc.SignerIndex]), i)
+	}
+
+	for i, tc := range v.ErrorCases {
+		pubkeys := selectHex(t, v.Pubkeys, tc.KeyIndices)
+		_, err := tweakKeyAgg(t, pubkeys, selectHex(t, v.Tweaks, tc.TweakIndices), tc.IsXOnly)
+		require.Error(t, err, i)
+	}
+}
+
+func TestMuSig2_SigAgg(t *testing.T) {
+	var v struct {
+		Pubkeys     []string `json:"pubkeys"`
+		PubNonces   []string `json:"pnonces"`
+		Tweaks      []string `json:"tweaks"`
+		PartialSigs []string `json:"psigs"`
+		Msg         string   `json:"msg"`
+		ValidCases  []struct {
+			AggNonce     string `json:"aggnonce"`
+			NonceIndices []int  `json:"nonce_indices"`
+			KeyIndices   []int  `json:"key_indices"`
+			TweakIndices []int  `json:"tweak_indices"`
+			IsXOnly      []bool `json:"is_xonly"`
+			PsigIndices  []int  `json:"psig_indices"`
+			Expected     string `json:"expected"`
+		} `json:"valid_test_cases"`
+		ErrorCases []struct {
+			AggNonce     string `json:"aggnonce"`
+			KeyIndices   []int  `json:"key_indices"`
+			TweakIndices []int  `json:"tweak_indices"`
+			IsXOnly      []bool `json:"is_xonly"`
+			PsigIndices  []int  `json:"psig_indices"`
+		} `json:"error_test_cases"`
+	}
+	readMuSig2Vectors(t, "sig_agg_vectors.json", &v)
+	msg := mustDecodeHex(t, v.Msg)
+
+	for i, tc := range v.ValidCases {
+		aggNonce, err := AggregateMuSig2Nonces(selectHex(t, v.PubNonces, tc.NonceIndices))
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, tc.AggNonce), aggNonce, i)
+
+		pubkeys := selectHex(t, v.Pubkeys, tc.KeyIndices)
+		keyAgg, err := tweakKeyAgg(t, pubkeys, selectHex(t, v.Tweaks, tc.TweakIndices), tc.IsXOnly)
+		require.NoError(t, err, i)
+		session, err := NewMuSig2Session(keyAgg, aggNonce, msg)
+		require.NoError(t, err, i)
+
+		sig, err := session.AggregatePartialSignatures(selectHex(t, v.PartialSigs, tc.PsigIndices))
+		require.NoError(t, err, i)
+		require.Equal(t, mustDecodeHex(t, tc.Expected), sig, i)
+		require.True(t, VerifyBIP340(keyAgg.XOnlyPublicKey(), msg, sig), i)
+	}
+
+	for i, tc := range v.ErrorCases {
+		pubkeys := selectHex(t, v.Pubkeys, tc.KeyIndices)
+		keyAgg, err := tweakKeyAgg(t, pubkeys, selectHex(t, v.Tweaks, tc.TweakIndices), tc.IsXOnly)
+		require.NoError(t, err, i)
+		session, err := NewMuSig2Session(keyAgg, mustDecodeHex(t, tc.AggNonce), msg)
+		require.NoError(t, err, i)
+		_, err = session.AggregatePartialSignatures(selectHex(t, v.PartialSigs, tc.PsigIndices))
+		require.Error(t, err, i)
+	}
+}
+
+func TestMuSig2_RoundTrip(t *testing.T) {
+	curve := NewCurve()
+	msg := []byte("taproot transaction")
+
+	const n = 3
+	sks := make([]Scalar, n)
+	pubkeys := make([][]byte, n)
+	for i := range sks {
+		sks[i] = curve.NewRandomScalar()
+		pubkeys[i] = curve.ScalarBaseMul(sks[i]).Encode()
+	}
+	pubkeys = SortPublicKeys(pubkeys)
+
+	keyAgg, err := AggregatePublicKeys(pubkeys)
+	require.NoError(t, err)
+	_, err = keyAgg.ApplyTweak(make([]byte, 31), true)
+	require.Error(t, err)
+	tweak := make([]byte, 32)
+	tweak[31] = 7
+	keyAgg, err = keyAgg.ApplyTweak(tweak, true)
+	require.NoError(t, err)
+
+	secNonces := make([][]byte, n)
+	pubNonces := make([][]byte, n)
+	for i := range sks {
+		secNonces[i], pubNonces[i], err = GenerateMuSig2Nonce(sks[i], curve.ScalarBaseMul(sks[i]).Encode(), keyAgg.XOnlyPublicKey(), msg, nil)
+		require.NoError(t, err)
+	}
+
+	aggNonce, err := AggregateMuSig2Nonces(pubNonces)
+	require.NoError(t, err)
+	session, err := NewMuSig2Session(keyAgg, aggNonce, msg)
+	require.NoError(t, err)
+
+	psigs := make([][]byte, n)
+	for i := range sks {
+		pk := curve.ScalarBaseMul(sks[i]).Encode()
+		psigs[i], err = session.Sign(secNonces[i], sks[i])
+		require.NoError(t, err)
+		require.True(t, session.VerifyPartialSignature(psigs[i], pubNonces[i], pk))
+		require.False(t, session.VerifyPartialSignature(psigs[i], pubNonces[(i+1)%n], pk))
+	}
+
+	sig, err := session.AggregatePartialSignatures(psigs)
+	require.NoError(t, err)
+	require.True(t, VerifyBIP340(keyAgg.XOnlyPublicKey(), msg, sig))
+	require.False(t, VerifyBIP340(keyAgg.XOnlyPublicKey(), []byte("other"), sig))
+
+	// a signer that's not one of the aggregated keys can't sign
+	other := curve.NewRandomScalar()
+	secNonce, _, err := GenerateMuSig2Nonce(other, curve.ScalarBaseMul(other).Encode(), nil, nil, nil)
+	require.NoError(t, err)
+	_, err = session.Sign(secNonce, other)
+	require.Error(t, err)
+}
@@ -2,159 +2,647 @@ package dleq
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 
 	"github.com/athanorlabs/go-dleq/types"
 )
 
-var errInputBytesTooShort = errors.New("input bytes too short")
+var (
+	errInputBytesTooShort = errors.New("input bytes too short")
+	errTrailingBytes      = errors.New("unexpected trailing bytes")
+)
+
+// seedLen is the length of the challenge seed of compact proofs.
+const seedLen = 32
+
+const (
+	// proofVersion1 proofs encode the challenge on every curve of each ring
+	// signature and every digit commitment.
+	proofVersion1 byte = 1
+	// proofVersion2 proofs are compact; see WithCompactEncoding.
+	proofVersion2 byte = 2
+)
+
+// signatureSchemesFlag is set in the version byte of proofs whose signatures
+// are each prefixed by their signature scheme; see WithSignatureScheme.
+// Proofs which only use the default scheme don't set it, so their encoding
+// is unchanged.
+const signatureSchemesFlag byte = 0x80
+
+func checkVersion(version byte) error {
+	if version != proofVersion1 && version != proofVersion2 {
+		return fmt.Errorf("unsupported proof version %d", version)
+	}
+
+	return nil
+}
+
+// header contains the parameters of an encoded proof.
+type header struct {
+	version byte
+	radix   uint64
+	bits    uint64
+	// signatureSchemes is whether signatureSchemesFlag is set.
+	signatureSchemes bool
+}
 
 // Serialize encodes the proof.
+//
+// The encoding is: version (1 byte) || radix (1 byte) ||
+// witness bit length (2 bytes, little-endian) || number of curves (1 byte) ||
+// commitment on each curve || digit proofs ||
+// for each curve: [signature scheme (1 byte)] || signature length (1 byte) || signature.
+//
+// The signature schemes are only encoded if a signature uses a scheme other
+// than its curve's default, in which case the version byte has its high bit set.
+//
+// Each digit proof is: digit commitment on each curve || challenges ||
+// responses on each curve.
+// For version 1 proofs, the challenges are a scalar on each curve. For
+// version 2 proofs, the challenges are a single 32-byte seed, and the digit
+// commitments of the last digit are omitted.
 func (p *Proof) Serialize() []byte {
-	b := append(p.CommitmentA.Encode(), p.CommitmentB.Encode()...)
-
-	// WARN: this assumes the bitlen of the witness is less than 256.
-	b = append(b, byte(len(p.proofs)))
-	for _, bp := range p.proofs {
-		b = append(b, bp.encode()...)
+	b := p.digitProofs.encode(p.Commitments)
+	schemes := p.hasSignatureSchemes()
+	if schemes {
+		b[0] |= signatureSchemesFlag
 	}
 
 	// WARN: this assumes the signature length is less than 256.
-	b = append(b, byte(len(p.signatureA.inner)))
-	b = append(b, p.signatureA.inner...)
-	b = append(b, byte(len(p.signatureB.inner)))
-	b = append(b, p.signatureB.inner...)
+	for _, sig := range p.signatures {
+		if schemes {
+			b = append(b, byte(sig.scheme))
+		}
+		b = append(b, byte(len(sig.inner)))
+		b = append(b, sig.inner...)
+	}
+	return b
+}
+
+// hasSignatureSchemes returns whether any of the proof's signatures use a
+// scheme other than the default.
+func (p *Proof) hasSignatureSchemes() bool {
+	for _, sig := range p.signatures {
+		if sig.scheme != types.DefaultSignatureScheme {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encode encodes the header, the commitments to the witness and the digit proofs.
+func (p *digitProofs) encode(commitments []types.Point) []byte {
+	b := p.encodeHeader(len(commitments))
+	for _, c := range commitments {
+		b = append(b, c.Encode()...)
+	}
+
+	return append(b, p.encodeProofs()...)
+}
+
+// encodeHeader encodes the version, radix, witness bit length and number of curves.
+func (p *digitProofs) encodeHeader(numCurves int) []byte {
+	b := []byte{p.version, byte(p.radix), 0, 0, byte(numCurves)}
+	binary.LittleEndian.PutUint16(b[2:], uint16(p.bits))
 	return b
 }
 
-func (p *bitProof) encode() []byte {
-	b := append(p.commitmentA.commitment.Encode(), p.commitmentB.commitment.Encode()...)
-	b = append(b, p.ringSig.eCurveA.Encode()...)
-	b = append(b, p.ringSig.eCurveB.Encode()...)
-	b = append(b, p.ringSig.a0.Encode()...)
-	b = append(b, p.ringSig.a1.Encode()...)
-	b = append(b, p.ringSig.b0.Encode()...)
-	b = append(b, p.ringSig.b1.Encode()...)
+// encodeProofs encodes the digit proofs.
+func (p *digitProofs) encodeProofs() []byte {
+	var b []byte
+	for i, bp := range p.proofs {
+		if p.version == proofVersion2 && i == len(p.proofs)-1 {
+			// the last commitments are derived by the verifier
+			b = append(b, bp.ringSig.encode(p.version)...)
+			continue
+		}
+
+		b = append(b, bp.encode(p.version)...)
+	}
+
+	return b
+}
+
+func (p *bitProof) encode(version byte) []byte {
+	var b []byte
+	for _, c := range p.commitments {
+		b = append(b, c.commitment.Encode()...)
+	}
+	return append(b, p.ringSig.encode(version)...)
+}
+
+func (s *ringSignature) encode(version byte) []byte {
+	var b []byte
+	if version == proofVersion2 {
+		b = append(b, s.seed...)
+	} else {
+		for _, e := range s.e {
+			b = append(b, e.Encode()...)
+		}
+	}
+
+	for _, responses := range s.s {
+		for _, r := range responses {
+			b = append(b, r.Encode()...)
+		}
+	}
 	return b
 }
 
 // Deserialize decodes the proof for the given curves.
 // The curves must match those passed into `NewProof`.
 func (p *Proof) Deserialize(curveA, curveB types.Curve, in []byte) error {
-	reader := bytes.NewBuffer(in)
+	return p.DeserializeForCurves([]types.Curve{curveA, curveB}, in)
+}
+
+// DeserializeForCurves decodes the proof for the given curves.
+// The curves must match those passed into `NewProofForCurves`, in the same order.
+//
+// Proofs on two curves may also be in the original encoding, which has no
+// header; see deserializeLegacy. Such proofs are serialized again in the
+// current encoding, as version 1 proofs.
+func (p *Proof) DeserializeForCurves(curves []types.Curve, in []byte) error {
+	if len(curves) < 2 {
+		return errTooFewCurves
+	}
+
+	err := p.deserialize(curves, in)
+	if err == nil || len(curves) != 2 {
+		return err
+	}
+
+	// the first byte of a proof in the original encoding is that of the
+	// commitment on curve A, which may look like a version byte, so it can
+	// only be told apart by failing to decode in the current encoding.
+	if p.deserializeLegacy(curves[0], curves[1], in) == nil {
+		return nil
+	}
 
-	pointLenA := curveA.CompressedPointSize()
-	pointLenB := curveB.CompressedPointSize()
+	return err
+}
 
-	if len(in) < pointLenA+pointLenB {
-		return errInputBytesTooShort
+// deserialize decodes a proof in the current encoding.
+func (p *Proof) deserialize(curves []types.Curve, in []byte) error {
+	d := newDecoder(in)
+	d.allowSignatureSchemes = true
+	h, commitments, err := p.digitProofs.decode(d, curves, minBitSize(curves), len(in))
+	if err != nil {
+		return err
 	}
 
-	// WARN: this assumes the groups have an encoded scalar length of 32!
-	const scalarLen = 32
+	signatures, err := d.readProofSignatures(h, len(curves))
+	if err != nil {
+		return err
+	}
 
-	var err error
-	p.CommitmentA, err = curveA.DecodeToPoint(reader.Next(pointLenA))
+	// every proof has a single encoding, which callers may hash
+	err = d.finished()
 	if err != nil {
 		return err
 	}
 
-	p.CommitmentB, err = curveB.DecodeToPoint(reader.Next(pointLenB))
+	p.Commitments = commitments
+	p.CommitmentA, p.CommitmentB = commitments[0], commitments[1]
+	p.signatures = signatures
+	return nil
+}
+
+// deserializeLegacy decodes a proof in the original encoding, which is:
+// commitment on curve A || commitment on curve B || number of bits (1 byte) ||
+// for each bit: commitment on curve A || commitment on curve B ||
+// challenge on curve A || challenge on curve B || responses on curve A ||
+// responses on curve B ||
+// for each curve: signature length (1 byte) || signature.
+//
+// These are version 1 proofs with a radix of 2, except that the two responses
+// of each ring are in the reverse order.
+func (p *Proof) deserializeLegacy(curveA, curveB types.Curve, in []byte) error {
+	curves := []types.Curve{curveA, curveB}
+	d := newDecoder(in)
+	commitments, err := d.readCommitments(curves)
 	if err != nil {
 		return err
 	}
 
-	if reader.Len() < 1 {
-		return errInputBytesTooShort
+	bits, err := d.readByte()
+	if err != nil {
+		return err
 	}
-	bitProofsLen := reader.Next(1)
 
-	// TODO put bitProofsLen + sigLens first so we know the total expected length?
-	minLenRemaining := (int(bitProofsLen[0]) * (pointLenA + pointLenB + scalarLen*6))
-	if reader.Len() < minLenRemaining {
-		return errInputBytesTooShort
+	maxBits := minBitSize(curves)
+	if bits == 0 || uint64(bits) > maxBits {
+		return fmt.Errorf("bit length must be between 1 and %d, got %d", maxBits, bits)
 	}
 
-	p.proofs = make([]bitProof, bitProofsLen[0])
-	for i := 0; i < int(bitProofsLen[0]); i++ {
-		bp := new(bitProof)
-		err = bp.decode(reader, curveA, curveB, scalarLen)
+	proofs := make([]bitProof, bits)
+	for i := range proofs {
+		err = proofs[i].decode(d, curves, proofVersion1, 2)
 		if err != nil {
 			return err
 		}
-		p.proofs[i] = *bp
+
+		for _, responses := range proofs[i].ringSig.s {
+			responses[0], responses[1] = responses[1], responses[0]
+		}
+	}
+
+	signatures, err := d.readSignatures(len(curves))
+	if err != nil {
+		return err
+	}
+
+	err = d.finished()
+	if err != nil {
+		return err
+	}
+
+	p.Commitments = commitments
+	p.CommitmentA, p.CommitmentB = commitments[0], commitments[1]
+	p.digitProofs = digitProofs{
+		version: proofVersion1,
+		radix:   2,
+		bits:    uint64(bits),
+		proofs:  proofs,
+	}
+	p.signatures = signatures
+	return nil
+}
+
+// decode decodes the header, the commitments to the witness and the digit
+// proofs, returning the header and the commitments. maxBits is the maximum accepted witness
+// bit length, and inLen is the total length of the input that's being decoded.
+func (p *digitProofs) decode(d *decoder, curves []types.Curve, maxBits uint64, inLen int) (*header, []types.Point, error) {
+	pointsLen := 0
+	for _, curve := range curves {
+		pointsLen += curve.CompressedPointSize()
 	}
+	headerLen := 5 + pointsLen
 
-	if reader.Len() < 1 {
-		return errInputBytesTooShort
+	if inLen < headerLen {
+		return nil, nil, errInputBytesTooShort
 	}
 
-	sigLen := reader.Next(1)
-	if reader.Len() < int(sigLen[0]) {
-		return errInputBytesTooShort
+	h, err := d.readHeader(len(curves))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	p.signatureA.inner = make([]byte, sigLen[0])
-	copy(p.signatureA.inner, reader.Next(int(sigLen[0])))
+	if h.bits == 0 || h.bits > maxBits {
+		return nil, nil, fmt.Errorf("bit length must be between 1 and %d, got %d", maxBits, h.bits)
+	}
 
-	if reader.Len() < 1 {
-		return errInputBytesTooShort
+	// TODO put sigLens first so we know the total expected length?
+	minLen := headerLen + minProofsLen(curves, h)
+	if inLen < minLen {
+		return nil, nil, errInputBytesTooShort
 	}
 
-	sigLen = reader.Next(1)
-	if reader.Len() < int(sigLen[0]) {
-		return errInputBytesTooShort
+	commitments, err := d.readCommitments(curves)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = p.decodeProofs(d, curves, h, commitments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h, commitments, nil
+}
+
+// decodeProofs decodes the digit proofs of a proof with the given header.
+func (p *digitProofs) decodeProofs(d *decoder, curves []types.Curve, h *header, commitments []types.Point) error {
+	p.version = h.version
+	p.radix = h.radix
+	p.bits = h.bits
+	p.proofs = make([]bitProof, 0, numDigits(h.bits, h.radix))
+	return d.readBitProofs(curves, h, commitments, func(_ uint64, bp *bitProof) error {
+		p.proofs = append(p.proofs, *bp)
+		return nil
+	})
+}
+
+// minProofsLen returns the minimum encoded length of the digit proofs of a
+// proof with the given header.
+func minProofsLen(curves []types.Curve, h *header) int {
+	pointsLen, scalarsLen := 0, 0
+	for _, curve := range curves {
+		pointsLen += curve.CompressedPointSize()
+		scalarsLen += curve.ScalarSize()
+	}
+
+	n := numDigits(h.bits, h.radix)
+	minLen := 0
+	for i := uint64(0); i < n; i++ {
+		ringSize := int(digitRingSize(h.bits, h.radix, i))
+		responsesLen := scalarsLen * ringSize
+		switch {
+		case h.version == proofVersion1:
+			minLen += pointsLen + scalarsLen + responsesLen
+		case i == n-1:
+			minLen += seedLen + responsesLen
+		default:
+			minLen += pointsLen + seedLen + responsesLen
+		}
+	}
+
+	return minLen
+}
+
+func (p *bitProof) decode(d *decoder, curves []types.Curve, version byte, ringSize uint64) error {
+	p.commitments = make([]commitment, len(curves))
+	for c, curve := range curves {
+		var err error
+		p.commitments[c].commitment, err = d.readPoint(curve)
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.ringSig.decode(d, curves, version, ringSize)
+}
+
+func (s *ringSignature) decode(d *decoder, curves []types.Curve, version byte, ringSize uint64) error {
+	if version == proofVersion2 {
+		seed, err := d.next(seedLen)
+		if err != nil {
+			return err
+		}
+
+		e, err := challengesFromSeed(curves, append([]byte{}, seed...))
+		if err != nil {
+			return err
+		}
+
+		s.e, s.seed = e.e, e.seed
+	} else {
+		s.e = make([]types.Scalar, len(curves))
+		for c, curve := range curves {
+			var err error
+			s.e[c], err = d.readScalar(curve)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.s = make([][]types.Scalar, len(curves))
+	for c, curve := range curves {
+		s.s[c] = make([]types.Scalar, ringSize)
+		for i := range s.s[c] {
+			var err error
+			s.s[c][i], err = d.readScalar(curve)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readBitProofs reads the digit proofs of a proof with the given header,
+// calling f with each proof as it's read. For compact proofs, the commitments
+// of the last digit are derived from the commitments to the witness and the
+// commitments of the other digits.
+func (d *decoder) readBitProofs(
+	curves []types.Curve,
+	h *header,
+	commitments []types.Point,
+	f func(i uint64, bp *bitProof) error,
+) error {
+	n := numDigits(h.bits, h.radix)
+
+	var sums []*commitmentSum
+	if h.version == proofVersion2 {
+		sums = make([]*commitmentSum, len(curves))
+		for c, curve := range curves {
+			sums[c] = newCommitmentSum(curve, h.radix)
+		}
+	}
+
+	for i := uint64(0); i < n; i++ {
+		ringSize := digitRingSize(h.bits, h.radix, i)
+
+		var (
+			bp  bitProof
+			err error
+		)
+		if h.version == proofVersion2 && i == n-1 {
+			bp.commitments = make([]commitment, len(curves))
+			for c := range curves {
+				bp.commitments[c].commitment = sums[c].remainder(commitments[c])
+			}
+			err = bp.ringSig.decode(d, curves, h.version, ringSize)
+		} else {
+			err = bp.decode(d, curves, h.version, ringSize)
+		}
+		if err != nil {
+			return err
+		}
+
+		for c := range sums {
+			sums[c].add(bp.commitments[c].commitment)
+		}
+
+		err = f(i, &bp)
+		if err != nil {
+			return err
+		}
 	}
 
-	p.signatureB.inner = make([]byte, sigLen[0])
-	copy(p.signatureB.inner, reader.Next(int(sigLen[0])))
 	return nil
 }
 
-func (p *bitProof) decode(r *bytes.Buffer, curveA, curveB types.Curve, scalarLen int) error {
-	pointLenA := curveA.CompressedPointSize()
-	pointLenB := curveB.CompressedPointSize()
+// decoder reads the elements of an encoded proof from an underlying reader.
+type decoder struct {
+	r   io.Reader
+	buf []byte
+	// allowSignatureSchemes is whether headers may set signatureSchemesFlag,
+	// which is only the case for Proof.
+	allowSignatureSchemes bool
+}
+
+func newDecoder(in []byte) *decoder {
+	return newStreamDecoder(bytes.NewReader(in))
+}
 
-	var err error
-	p.commitmentA.commitment, err = curveA.DecodeToPoint(r.Next(pointLenA))
+func newStreamDecoder(r io.Reader) *decoder {
+	return &decoder{
+		r: r,
+	}
+}
+
+// next reads exactly n bytes. The returned slice is only valid until the
+// next call.
+func (d *decoder) next(n int) ([]byte, error) {
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+
+	b := d.buf[:n]
+	_, err := io.ReadFull(d.r, b)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, errInputBytesTooShort
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	p.commitmentB.commitment, err = curveB.DecodeToPoint(r.Next(pointLenB))
+	return b, nil
+}
+
+// finished returns an error if there are unread bytes.
+func (d *decoder) finished() error {
+	_, err := d.next(1)
+	if errors.Is(err, errInputBytesTooShort) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	p.ringSig.eCurveA, err = curveA.DecodeToScalar(r.Next(scalarLen))
+	return errTrailingBytes
+}
+
+func (d *decoder) readByte() (byte, error) {
+	b, err := d.next(1)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	p.ringSig.eCurveB, err = curveB.DecodeToScalar(r.Next(scalarLen))
+	return b[0], nil
+}
+
+// readHeader reads the proof version, radix, witness bit length and number of
+// curves, which must equal numCurves.
+// The bit length is not checked against the curves.
+func (d *decoder) readHeader(numCurves int) (*header, error) {
+	b, err := d.next(5)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	version, radix, bits := b[0], uint64(b[1]), uint64(binary.LittleEndian.Uint16(b[2:4]))
+	signatureSchemes := d.allowSignatureSchemes && version&signatureSchemesFlag != 0
+	if signatureSchemes {
+		version &^= signatureSchemesFlag
 	}
 
-	p.ringSig.a0, err = curveA.DecodeToScalar(r.Next(scalarLen))
+	if int(b[4]) != numCurves {
+		return nil, fmt.Errorf("proof is for %d curves, expected %d", b[4], numCurves)
+	}
+
+	err = checkVersion(version)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	p.ringSig.a1, err = curveA.DecodeToScalar(r.Next(scalarLen))
+	err = checkRadix(radix)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	p.ringSig.b0, err = curveB.DecodeToScalar(r.Next(scalarLen))
+	return &header{
+		version:          version,
+		radix:            radix,
+		bits:             bits,
+		signatureSchemes: signatureSchemes,
+	}, nil
+}
+
+func (d *decoder) readPoint(curve types.Curve) (types.Point, error) {
+	b, err := d.next(curve.CompressedPointSize())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	p.ringSig.b1, err = curveB.DecodeToScalar(r.Next(scalarLen))
+	return curve.DecodeToPoint(b)
+}
+
+func (d *decoder) readScalar(curve types.Curve) (types.Scalar, error) {
+	b, err := d.next(curve.ScalarSize())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return curve.DecodeToScalar(b)
+}
+
+func (d *decoder) readCommitments(curves []types.Curve) ([]types.Point, error) {
+	commitments := make([]types.Point, len(curves))
+	for c, curve := range curves {
+		var err error
+		commitments[c], err = d.readPoint(curve)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return commitments, nil
+}
+
+func (d *decoder) readSignature() (signature, error) {
+	sigLen, err := d.readByte()
+	if err != nil {
+		return signature{}, err
+	}
+
+	b, err := d.next(int(sigLen))
+	if err != nil {
+		return signature{}, err
+	}
+
+	inner := make([]byte, sigLen)
+	copy(inner, b)
+	return signature{
+		inner: inner,
+	}, nil
+}
+
+func (d *decoder) readSignatures(numCurves int) ([]signature, error) {
+	signatures := make([]signature, numCurves)
+	for c := range signatures {
+		var err error
+		signatures[c], err = d.readSignature()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return signatures, nil
+}
+
+// readSchemeSignatures reads a signature on each curve, each prefixed by its
+// signature scheme.
+func (d *decoder) readSchemeSignatures(numCurves int) ([]signature, error) {
+	signatures := make([]signature, numCurves)
+	for c := range signatures {
+		scheme, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+
+		signatures[c], err = d.readSignature()
+		if err != nil {
+			return nil, err
+		}
+
+		signatures[c].scheme = types.SignatureScheme(scheme)
+	}
+
+	return signatures, nil
+}
+
+// readProofSignatures reads the signatures of a Proof with the given header.
+func (d *decoder) readProofSignatures(h *header, numCurves int) ([]signature, error) {
+	if h.signatureSchemes {
+		return d.readSchemeSignatures(numCurves)
+	}
+
+	return d.readSignatures(numCurves)
 }
@@ -1,6 +1,10 @@
 package dleq
 
 import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -29,20 +33,183 @@ func TestProof_Serde(t *testing.T) {
 	require.Equal(t, len(proof.proofs), len(deser.proofs))
 
 	for i := range proof.proofs {
-		require.Equal(t, proof.proofs[i].commitmentA.commitment, deser.proofs[i].commitmentA.commitment)
-		require.True(t, proof.proofs[i].commitmentB.commitment.Equals(deser.proofs[i].commitmentB.commitment))
-		require.Equal(t, proof.proofs[i].ringSig.eCurveA, deser.proofs[i].ringSig.eCurveA)
-		require.Equal(t, proof.proofs[i].ringSig.eCurveB, deser.proofs[i].ringSig.eCurveB)
-		require.Equal(t, proof.proofs[i].ringSig.a0, deser.proofs[i].ringSig.a0)
-		require.Equal(t, proof.proofs[i].ringSig.a1, deser.proofs[i].ringSig.a1)
-		require.Equal(t, proof.proofs[i].ringSig.b0, deser.proofs[i].ringSig.b0)
-		require.Equal(t, proof.proofs[i].ringSig.b1, deser.proofs[i].ringSig.b1)
+		require.Equal(t, proof.proofs[i].commitments[0].commitment, deser.proofs[i].commitments[0].commitment)
+		require.True(t, proof.proofs[i].commitments[1].commitment.Equals(deser.proofs[i].commitments[1].commitment))
+		require.Equal(t, proof.proofs[i].ringSig.e, deser.proofs[i].ringSig.e)
+		require.Equal(t, proof.proofs[i].ringSig.s, deser.proofs[i].ringSig.s)
 	}
 
-	require.Equal(t, proof.signatureA, deser.signatureA)
-	require.Equal(t, proof.signatureB, deser.signatureB)
+	require.Equal(t, proof.signatures, deser.signatures)
 
 	err = deser.Verify(curveA, curveB)
 	require.NoError(t, err)
 	t.Logf("size of serialized proof: %d bytes", len(ser))
+
+	// trailing bytes are rejected, so the proof has a single encoding
+	err = new(Proof).Deserialize(curveA, curveB, append(ser, 0))
+	require.ErrorIs(t, err, errTrailingBytes)
+}
+
+func TestVerifyStream(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	proof, err := NewProof(curveA, curveB, x)
+	require.NoError(t, err)
+	ser := proof.Serialize()
+
+	commitmentA, commitmentB, err := VerifyStream(curveA, curveB, bytes.NewReader(ser))
+	require.NoError(t, err)
+	require.True(t, proof.CommitmentA.Equals(commitmentA))
+	require.True(t, proof.CommitmentB.Equals(commitmentB))
+
+	_, _, err = VerifyStream(curveA, curveB, bytes.NewReader(ser[:len(ser)-1]))
+	require.ErrorIs(t, err, errInputBytesTooShort)
+
+	// corrupt the challenge of the third bit proof; verification should fail
+	// without reading the rest of the proof.
+	corrupted := make([]byte, len(ser))
+	copy(corrupted, ser)
+	bitProofLen := len(proof.proofs[0].encode(proofVersion1))
+	headerLen := 5 + curveA.CompressedPointSize() + curveB.CompressedPointSize()
+	corrupted[headerLen+2*bitProofLen+curveA.CompressedPointSize()+curveB.CompressedPointSize()+1] ^= 1
+
+	r := bytes.NewReader(corrupted)
+	_, _, err = VerifyStream(curveA, curveB, r)
+	require.Error(t, err)
+	require.Equal(t, len(corrupted)-headerLen-3*bitProofLen, r.Len())
+}
+
+func TestProof_Serde_Radix(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+	proof, err := NewProof(curveA, curveB, x, WithRadix(4))
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(Proof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), deser.radix)
+	require.Equal(t, ser, deser.Serialize())
+
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	_, _, err = VerifyStream(curveA, curveB, bytes.NewReader(ser))
+	require.NoError(t, err)
+	t.Logf("size of serialized radix 4 proof: %d bytes", len(ser))
+}
+
+func TestProof_Serde_Compact(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+
+	for _, radix := range []uint64{2, 4} {
+		proof, err := NewProof(curveA, curveB, x, WithRadix(radix))
+		require.NoError(t, err)
+		compact, err := NewProof(curveA, curveB, x, WithRadix(radix), WithCompactEncoding())
+		require.NoError(t, err)
+		err = compact.Verify(curveA, curveB)
+		require.NoError(t, err)
+
+		ser := compact.Serialize()
+		deser := new(Proof)
+		err = deser.Deserialize(curveA, curveB, ser)
+		require.NoError(t, err)
+		require.Equal(t, proofVersion2, deser.version)
+		require.Equal(t, ser, deser.Serialize())
+
+		// the omitted commitments are reconstructed when decoding
+		last := len(compact.proofs) - 1
+		for c := range compact.Commitments {
+			require.True(t, compact.proofs[last].commitments[c].commitment.Equals(deser.proofs[last].commitments[c].commitment))
+		}
+
+		err = deser.Verify(curveA, curveB)
+		require.NoError(t, err)
+		_, _, err = VerifyStream(curveA, curveB, bytes.NewReader(ser))
+		require.NoError(t, err)
+
+		// the derived last commitment must still be a valid digit commitment
+		corrupted := make([]byte, len(ser))
+		copy(corrupted, ser)
+		corrupted[6] ^= 1
+		err = deser.Deserialize(curveA, curveB, corrupted)
+		if err == nil {
+			err = deser.Verify(curveA, curveB)
+		}
+		require.Error(t, err)
+
+		t.Logf("radix %d: version 1 proof is %d bytes, version 2 proof is %d bytes",
+			radix, len(proof.Serialize()), len(ser))
+	}
+}
+
+func TestProof_Serde_Bits(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := generateRandomBits(100)
+	require.NoError(t, err)
+	proof, err := NewProof(curveA, curveB, x, WithBits(100), WithRadix(8))
+	require.NoError(t, err)
+
+	ser := proof.Serialize()
+	deser := new(Proof)
+	err = deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), deser.Bits())
+	require.Equal(t, ser, deser.Serialize())
+
+	err = deser.Verify(curveA, curveB, WithMinBits(100))
+	require.NoError(t, err)
+	_, _, err = VerifyStream(curveA, curveB, bytes.NewReader(ser), WithMinBits(100))
+	require.NoError(t, err)
+	_, _, err = VerifyStream(curveA, curveB, bytes.NewReader(ser))
+	require.Error(t, err)
+}
+
+// TestProof_DeserializeBaseline decodes a proof serialized by the original
+// encoding, which has no header. Its first byte is 0x02, which is also the
+// version byte of compact proofs.
+func TestProof_DeserializeBaseline(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	in, err := os.ReadFile("testdata/baseline_proof.hex")
+	require.NoError(t, err)
+	ser, err := hex.DecodeString(strings.TrimSpace(string(in)))
+	require.NoError(t, err)
+	require.Equal(t, proofVersion2, ser[0])
+
+	proof := new(Proof)
+	err = proof.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, uint64(252), proof.Bits())
+	err = proof.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	// the proof is serialized again in the current encoding
+	deser := new(Proof)
+	err = deser.Deserialize(curveA, curveB, proof.Serialize())
+	require.NoError(t, err)
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+	require.True(t, deser.CommitmentA.Equals(proof.CommitmentA))
+
+	err = proof.Deserialize(curveA, curveB, ser[:len(ser)-1])
+	require.Error(t, err)
+	err = proof.Deserialize(curveA, curveB, append(ser[:len(ser):len(ser)], 0))
+	require.Error(t, err)
+
+	// tamper with a response
+	ser[200] ^= 1
+	err = proof.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	err = proof.Verify(curveA, curveB)
+	require.Error(t, err)
 }
@@ -0,0 +1,40 @@
+package dleq
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/athanorlabs/go-dleq/internal/littleendian"
+)
+
+var errTweakTooLarge = errors.New("tweaked witness could exceed the order of a curve")
+
+// DeriveChild verifies the proof and returns the child keys X_A + t*G_A and
+// X_B + t*G_B for the public tweak t, which is in little-endian. No new proof
+// is needed, as anyone can derive the child keys from the parent's.
+//
+// The child keys have the same discrete logarithm x + t on both curves only if
+// the sum doesn't wrap around the order of either curve. Since the proof shows
+// that x < 2^n, where n is its bit length, the tweak must satisfy
+// (2^n - 1) + t < 2^m, where m is the minimum BitSize of the curves.
+func (p *Proof) DeriveChild(curveA, curveB Curve, t []byte, opts ...VerifyOption) (Point, Point, error) {
+	err := p.Verify(curveA, curveB, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tweak := littleendian.Int(t)
+	max := new(big.Int).Lsh(big.NewInt(1), uint(p.bits))
+	max.Sub(max, big.NewInt(1))
+	max.Add(max, tweak)
+	if uint64(max.BitLen()) > minBitSize([]Curve{curveA, curveB}) {
+		return nil, nil, errTweakTooLarge
+	}
+
+	// t may have trailing zero bytes, so it's re-encoded for each curve
+	tA := curveA.ScalarFromBytes(littleendian.Bytes(tweak, curveA.ScalarSize()))
+	tB := curveB.ScalarFromBytes(littleendian.Bytes(tweak, curveB.ScalarSize()))
+	XA := p.CommitmentA.Add(curveA.ScalarBaseMul(tA))
+	XB := p.CommitmentB.Add(curveB.ScalarBaseMul(tB))
+	return XA, XB, nil
+}
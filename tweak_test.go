@@ -0,0 +1,50 @@
+package dleq
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/internal/littleendian"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+)
+
+func TestDeriveChild(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := generateRandomBits(128)
+	require.NoError(t, err)
+	proof, err := NewProof(curveA, curveB, x, WithBits(128))
+	require.NoError(t, err)
+
+	// trailing zero bytes are allowed
+	tweak := append([]byte{0x01, 0x02, 0x03}, make([]byte, 31)...)
+	XA, XB, err := proof.DeriveChild(curveA, curveB, tweak, WithMinBits(128))
+	require.NoError(t, err)
+
+	// the child keys are for x + t on both curves
+	child := new(big.Int).Add(littleendian.Int(x), littleendian.Int(tweak))
+	childProof, err := NewProof(curveA, curveB, littleendian.Bytes(child, 32))
+	require.NoError(t, err)
+	require.True(t, childProof.CommitmentA.Equals(XA))
+	require.True(t, childProof.CommitmentB.Equals(XB))
+
+	// 2^128 - 1 + t must be smaller than 2^252
+	max := new(big.Int).Lsh(big.NewInt(1), 252)
+	max.Sub(max, new(big.Int).Lsh(big.NewInt(1), 128))
+	_, _, err = proof.DeriveChild(curveA, curveB, littleendian.Bytes(max, 32), WithMinBits(128))
+	require.NoError(t, err)
+	max.Add(max, big.NewInt(1))
+	_, _, err = proof.DeriveChild(curveA, curveB, littleendian.Bytes(max, 32), WithMinBits(128))
+	require.ErrorIs(t, err, errTweakTooLarge)
+
+	// the proof is verified
+	_, _, err = proof.DeriveChild(curveA, curveB, tweak)
+	require.Error(t, err)
+	proof.CommitmentB = XB
+	proof.Commitments[1] = XB
+	_, _, err = proof.DeriveChild(curveA, curveB, tweak, WithMinBits(128))
+	require.Error(t, err)
+}
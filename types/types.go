@@ -1,13 +1,21 @@
 package types
 
+import (
+	"math/big"
+)
+
 type Curve interface {
 	BitSize() uint64
 	CompressedPointSize() int
+	// ScalarSize returns the length of an encoded scalar in bytes.
+	ScalarSize() int
 	BasePoint() Point
 	AltBasePoint() Point
 	NewRandomScalar() Scalar
 	ScalarFromInt(uint32) Scalar
-	ScalarFromBytes([32]byte) Scalar
+	// ScalarFromBytes returns the scalar with the given little-endian
+	// encoding, which is zero-extended if it's shorter than ScalarSize.
+	ScalarFromBytes([]byte) Scalar
 	HashToScalar([]byte) (Scalar, error)
 	ScalarBaseMul(Scalar) Point
 	ScalarMul(Scalar, Point) Point
@@ -40,3 +48,39 @@ type Point interface {
 	IsZero() bool
 	Equals(other Point) bool
 }
+
+// MultiScalarMuler is optionally implemented by curves which support
+// variable-time multi-scalar multiplication. It is only used for verification,
+// where the inputs are public.
+type MultiScalarMuler interface {
+	VarTimeMultiScalarMul(scalars []Scalar, points []Point) Point
+}
+
+// Orderer is optionally implemented by curves to return the order of the
+// group generated by their base point.
+type Orderer interface {
+	Order() *big.Int
+}
+
+// SignatureScheme identifies the signature scheme of a proof of knowledge of
+// the witness. Its value is recorded in serialized proofs.
+type SignatureScheme byte
+
+const (
+	// DefaultSignatureScheme is the scheme of the curve's Sign and Verify methods.
+	DefaultSignatureScheme SignatureScheme = 0
+	// BIP340 is the BIP340 Schnorr signature scheme on secp256k1.
+	BIP340 SignatureScheme = 1
+	// Monero is the Schnorr signature scheme of Monero's
+	// crypto::generate_signature on ed25519.
+	Monero SignatureScheme = 2
+)
+
+// SchemeSigner is optionally implemented by curves which support signature
+// schemes other than their default one.
+// The message of a signature is the encoded point msgPoint, as for Sign.
+type SchemeSigner interface {
+	SupportsSignatureScheme(SignatureScheme) bool
+	SignWithScheme(scheme SignatureScheme, s Scalar, msgPoint Point) ([]byte, error)
+	VerifyWithScheme(scheme SignatureScheme, pubkey, msgPoint Point, sig []byte) bool
+}
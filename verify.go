@@ -3,109 +3,315 @@ package dleq
 import (
 	"errors"
 	"fmt"
+	"io"
+
+	"github.com/athanorlabs/go-dleq/types"
 )
 
 // Verify verifies the proof is valid against the given curves.
+// By default, the proof must be for a witness of the full bit length; see WithMinBits.
 // TODO: encode curves into proof somehow?
-func (p *Proof) Verify(curveA, curveB Curve) error {
-	commitmentsA := make([]commitment, len(p.proofs))
-	for i := range commitmentsA {
-		commitmentsA[i] = p.proofs[i].commitmentA
-	}
+func (p *Proof) Verify(curveA, curveB Curve, opts ...VerifyOption) error {
+	return p.VerifyForCurves([]Curve{curveA, curveB}, opts...)
+}
 
-	err := verifyCommitmentsSum(curveA, commitmentsA, p.CommitmentA)
+// VerifyForCurves verifies the proof is valid against the given curves, which
+// must be the curves passed into `NewProofForCurves`, in the same order.
+// By default, the proof must be for a witness of the full bit length; see WithMinBits.
+func (p *Proof) VerifyForCurves(curves []Curve, opts ...VerifyOption) error {
+	err := p.checkParameters(curves, opts)
 	if err != nil {
-		return fmt.Errorf("failed to verify commitment on curve A: %w", err)
+		return err
 	}
 
-	commitmentsB := make([]commitment, len(p.proofs))
-	for i := range commitmentsB {
-		commitmentsB[i] = p.proofs[i].commitmentB
+	err = p.digitProofs.verify(curves, p.Commitments)
+	if err != nil {
+		return err
 	}
 
-	err = verifyCommitmentsSum(curveB, commitmentsB, p.CommitmentB)
-	if err != nil {
-		return fmt.Errorf("failed to verify commitment on curve B: %w", err)
+	return verifySignatures(curves, p.Commitments, p.signatures)
+}
+
+var (
+	errInvalidNumCommitments = errors.New("invalid number of commitments")
+	errCommitmentMismatch    = errors.New("CommitmentA and CommitmentB must equal the first two commitments")
+)
+
+// checkParameters checks the parameters of the proof are valid and accepted
+// by the verifier.
+func (p *Proof) checkParameters(curves []Curve, opts []VerifyOption) error {
+	if len(curves) < 2 {
+		return errTooFewCurves
 	}
 
-	// verify signatures
-	ok := curveA.Verify(p.CommitmentA, p.CommitmentA, p.signatureA.inner)
-	if !ok {
-		return fmt.Errorf("failed to verify signature on commitment A")
+	if len(p.Commitments) != len(curves) || len(p.signatures) != len(curves) {
+		return errInvalidNumCommitments
 	}
 
-	ok = curveB.Verify(p.CommitmentB, p.CommitmentB, p.signatureB.inner)
-	if !ok {
-		return fmt.Errorf("failed to verify signature on commitment B")
+	// callers read CommitmentA and CommitmentB after verification, so they
+	// must be the commitments that are verified.
+	if p.CommitmentA == nil || p.CommitmentB == nil || p.Commitments[0] == nil || p.Commitments[1] == nil ||
+		!p.CommitmentA.Equals(p.Commitments[0]) || !p.CommitmentB.Equals(p.Commitments[1]) {
+		return errCommitmentMismatch
 	}
 
-	// now calculate challenges and verify
-	bits := min(curveA.BitSize(), curveB.BitSize())
-	for i := uint64(0); i < bits; i++ {
-		proof := p.proofs[i]
+	return p.digitProofs.checkParameters(curves, opts)
+}
 
-		aG := curveA.ScalarMul(proof.ringSig.a1, curveA.AltBasePoint())
-		eCA := proof.commitmentA.commitment.ScalarMul(proof.ringSig.eCurveA)
+func (p *digitProofs) checkParameters(curves []Curve, opts []VerifyOption) error {
+	err := checkVersion(p.version)
+	if err != nil {
+		return err
+	}
+
+	err = checkRadix(p.radix)
+	if err != nil {
+		return err
+	}
 
-		bH := curveB.ScalarMul(proof.ringSig.b1, curveB.AltBasePoint())
-		eCB := proof.commitmentB.commitment.ScalarMul(proof.ringSig.eCurveB)
+	maxBits := minBitSize(curves)
+	return newVerifyOptions(opts, maxBits).checkBits(p.bits, maxBits)
+}
 
-		eA1, err := hashToScalar(
-			curveA,
-			proof.commitmentA.commitment,
-			proof.commitmentB.commitment,
-			aG.Sub(eCA),
-			bH.Sub(eCB),
-		)
+// verify verifies that the digit commitments on each curve sum to the given
+// commitment on that curve, and verifies the ring signature of each digit.
+// The parameters must have already been checked.
+func (p *digitProofs) verify(curves []Curve, commitments []Point) error {
+	for c, curve := range curves {
+		digitCommitments, err := p.digitCommitments(len(curves), c)
 		if err != nil {
 			return err
 		}
 
-		eB1, err := hashToScalar(
-			curveB,
-			proof.commitmentA.commitment,
-			proof.commitmentB.commitment,
-			aG.Sub(eCA),
-			bH.Sub(eCB),
-		)
+		err = verifyCommitmentsSum(curve, digitCommitments, commitments[c], p.radix)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to verify commitment on curve %s: %w", curveLabel(c), err)
 		}
+	}
 
-		commitmentAMinusOne := proof.commitmentA.commitment.Sub(curveA.BasePoint())
-		commitmentBMinusOne := proof.commitmentB.commitment.Sub(curveB.BasePoint())
-
-		aG = curveA.ScalarMul(proof.ringSig.a0, curveA.AltBasePoint())
-		bH = curveB.ScalarMul(proof.ringSig.b0, curveB.AltBasePoint())
-		ecA := commitmentAMinusOne.ScalarMul(eA1)
-		ecB := commitmentBMinusOne.ScalarMul(eB1)
-
-		eA0, err := hashToScalar(
-			curveA,
-			proof.commitmentA.commitment,
-			proof.commitmentB.commitment,
-			aG.Sub(ecA),
-			bH.Sub(ecB),
-		)
-		if err != nil {
-			return err
+	return p.verifyRings(curves)
+}
+
+// digitCommitments returns the digit commitments on the curve at index c,
+// checking the number of digits and the number of commitments of each digit.
+func (p *digitProofs) digitCommitments(numCurves, c int) ([]commitment, error) {
+	n := numDigits(p.bits, p.radix)
+	if uint64(len(p.proofs)) != n {
+		return nil, fmt.Errorf("invalid number of bit proofs: expected %d, got %d", n, len(p.proofs))
+	}
+
+	digitCommitments := make([]commitment, len(p.proofs))
+	for i := range digitCommitments {
+		if len(p.proofs[i].commitments) != numCurves {
+			return nil, errInvalidNumCommitments
 		}
 
-		eB0, err := hashToScalar(
-			curveB,
-			proof.commitmentA.commitment,
-			proof.commitmentB.commitment,
-			aG.Sub(ecA),
-			bH.Sub(ecB),
-		)
+		digitCommitments[i] = p.proofs[i].commitments[c]
+	}
+
+	return digitCommitments, nil
+}
+
+// verifyRings verifies the ring signature of each digit.
+func (p *digitProofs) verifyRings(curves []Curve) error {
+	for i := range p.proofs {
+		err := p.proofs[i].verify(curves, p.version, digitRingSize(p.bits, p.radix, uint64(i)))
 		if err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+// verifySignatures verifies the proofs of knowledge of the witness on every curve.
+func verifySignatures(curves []Curve, commitments []Point, signatures []signature) error {
+	for c, curve := range curves {
+		ok := signatures[c].verify(curve, commitments[c], commitments[c])
+		if !ok {
+			return fmt.Errorf("failed to verify signature on commitment %s", curveLabel(c))
+		}
+	}
+
+	return nil
+}
+
+// verify verifies the signature of msgPoint by pubkey.
+func (s *signature) verify(curve Curve, pubkey, msgPoint Point) bool {
+	if s.scheme == types.DefaultSignatureScheme {
+		return curve.Verify(pubkey, msgPoint, s.inner)
+	}
+
+	signer, ok := curve.(types.SchemeSigner)
+	if !ok || !signer.SupportsSignatureScheme(s.scheme) {
+		return false
+	}
+
+	return signer.VerifyWithScheme(s.scheme, pubkey, msgPoint, s.inner)
+}
+
+// curveLabel returns the label of the curve at index c in error messages,
+// ie. A for the first curve, B for the second, and so on.
+func curveLabel(c int) string {
+	if c < 26 {
+		return string(rune('A' + c))
+	}
+
+	return fmt.Sprint(c)
+}
+
+// verify verifies the ring signature for a single digit.
+func (p *bitProof) verify(curves []Curve, version byte, ringSize uint64) error {
+	if len(p.commitments) != len(curves) || len(p.ringSig.e) != len(curves) || len(p.ringSig.s) != len(curves) {
+		return errors.New("invalid ring signature size")
+	}
+
+	points := make([]Point, len(curves))
+	for c := range curves {
+		if uint64(len(p.ringSig.s[c])) != ringSize {
+			return errors.New("invalid ring signature size")
+		}
+
+		points[c] = p.commitments[c].commitment
+	}
+
+	e := &ringChallenge{
+		e: p.ringSig.e,
+	}
+	R, err := walkRing(curves, version, nil, points, e, p.ringSig.s)
+	if err != nil {
+		return err
+	}
 
-		if !eA0.Eq(proof.ringSig.eCurveA) || !eB0.Eq(proof.ringSig.eCurveB) {
+	// the ring closes if the last member's R hashes to the first challenge
+	e, err = ringChallenges(curves, version, nil, points, R)
+	if err != nil {
+		return err
+	}
+
+	for c := range curves {
+		if !e.e[c].Eq(p.ringSig.e[c]) {
 			return errors.New("invalid proof")
 		}
 	}
 
 	return nil
 }
+
+// walkRing computes the R of each ring member in turn, starting with the
+// challenge e of the first member, and returns the R of the last member on
+// each curve. The number of responses on each curve must have been checked.
+func walkRing(curves []Curve, version byte, transcript []byte, points []Point, e *ringChallenge, s [][]Scalar) ([]Point, error) {
+	R, err := ringPoints(curves, version, transcript, points, e, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return R[len(R)-1], nil
+}
+
+// ringPoints is walkRing, returning the R of every ring member on each curve,
+// ie. R[i][c] is the R of member i on curve c.
+func ringPoints(curves []Curve, version byte, transcript []byte, points []Point, e *ringChallenge, s [][]Scalar) ([][]Point, error) {
+	ringSize := uint64(len(s[0]))
+	R := make([][]Point, ringSize)
+	for i := uint64(0); i < ringSize; i++ {
+		if i > 0 {
+			var err error
+			e, err = ringChallenges(curves, version, transcript, points, R[i-1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		R[i] = make([]Point, len(curves))
+		for c, curve := range curves {
+			member := ringMember(curve, points[c], i)
+			sH := curve.ScalarMul(s[c][i], curve.AltBasePoint())
+			R[i][c] = sH.Sub(member.ScalarMul(e.e[c]))
+		}
+	}
+
+	return R, nil
+}
+
+// VerifyStream decodes and verifies a serialized proof from the given reader,
+// returning the commitments to the witness on each curve.
+// By default, the proof must be for a witness of the full bit length; see WithMinBits.
+// Unlike Deserialize followed by Verify, the bit proofs are verified as they
+// are read, so memory usage does not depend on the number of bits and
+// verification stops at the first invalid bit. Unlike Deserialize, it
+// doesn't accept proofs in the original encoding.
+func VerifyStream(curveA, curveB Curve, r io.Reader, opts ...VerifyOption) (Point, Point, error) {
+	commitments, err := VerifyStreamForCurves([]Curve{curveA, curveB}, r, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return commitments[0], commitments[1], nil
+}
+
+// VerifyStreamForCurves is VerifyStream for a proof on any number of curves.
+// It returns the commitments to the witness on each curve.
+func VerifyStreamForCurves(curves []Curve, r io.Reader, opts ...VerifyOption) ([]Point, error) {
+	if len(curves) < 2 {
+		return nil, errTooFewCurves
+	}
+
+	d := newStreamDecoder(r)
+	d.allowSignatureSchemes = true
+
+	h, err := d.readHeader(len(curves))
+	if err != nil {
+		return nil, err
+	}
+
+	maxBits := minBitSize(curves)
+	err = newVerifyOptions(opts, maxBits).checkBits(h.bits, maxBits)
+	if err != nil {
+		return nil, err
+	}
+
+	commitments, err := d.readCommitments(curves)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make([]*commitmentSum, len(curves))
+	for c, curve := range curves {
+		sums[c] = newCommitmentSum(curve, h.radix)
+	}
+
+	err = d.readBitProofs(curves, h, commitments, func(i uint64, bp *bitProof) error {
+		err := bp.verify(curves, h.version, digitRingSize(h.bits, h.radix, i))
+		if err != nil {
+			return fmt.Errorf("failed to verify bit %d: %w", i, err)
+		}
+
+		for c := range curves {
+			sums[c].add(bp.commitments[c].commitment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for c := range curves {
+		if !sums[c].equals(commitments[c]) {
+			return nil, fmt.Errorf("failed to verify commitment on curve %s: %w", curveLabel(c), errCommitmentsSum)
+		}
+	}
+
+	signatures, err := d.readProofSignatures(h, len(curves))
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifySignatures(curves, commitments, signatures)
+	if err != nil {
+		return nil, err
+	}
+
+	return commitments, nil
+}
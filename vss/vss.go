@@ -0,0 +1,454 @@
+// Package vss implements Feldman verifiable secret sharing of a witness across
+// two curves, such as splitting a swap secret t-of-n among watchtowers.
+//
+// The witness x is shared with a random polynomial f of degree t-1 over the
+// scalar field of curve B, with f(0) = x. The dealer publishes a Dealing,
+// which contains:
+//   - a proof that X_A = x*G_A and X_B = x*G_B have the same discrete logarithm,
+//   - Feldman commitments a_k*G_B to the coefficients of f, the first being X_B,
+//   - for each share f(i), a full-range proof that its public keys on both
+//     curves have the same discrete logarithm.
+//
+// Anyone can verify that the public key of each share on curve B is f(i)*G_B,
+// as derived from the coefficient commitments, and that its public key on
+// curve A has the same discrete logarithm. Any t shares reconstruct x, which
+// is smaller than 2^n for the proof's bit length n, so it's the discrete
+// logarithm of X_A as well as X_B.
+//
+// There are no commitments to the coefficients on curve A. f is over the
+// order of curve B, so f(i) is reduced modulo that order, and commitments
+// a_k*G_A couldn't be combined into f(i)*G_A. Instead, each share's
+// full-range proof shows that its public key on curve A has the same discrete
+// logarithm as its public key on curve B, which is checked against the
+// coefficient commitments.
+package vss
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	dleq "github.com/athanorlabs/go-dleq"
+	"github.com/athanorlabs/go-dleq/internal/fullrange"
+	"github.com/athanorlabs/go-dleq/internal/littleendian"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+// MaxShares is the maximum number of shares of a dealing.
+const MaxShares = 255
+
+var (
+	errInputBytesTooShort = errors.New("input bytes too short")
+	errInvalidDealing     = errors.New("invalid dealing")
+	errDealOptions        = errors.New("only WithRadix and WithCompactEncoding can be set for a dealing")
+)
+
+// Dealing contains the public commitments of a sharing of a witness.
+type Dealing struct {
+	// Proof proves that the shared witness is the discrete logarithm of
+	// CommitmentA and CommitmentB.
+	Proof *dleq.Proof
+	// Commitments contains the commitment to each coefficient of the sharing
+	// polynomial on curve B. The first is Proof.CommitmentB.
+	Commitments []types.Point
+	// ShareProofs contains, for each share, a proof that its public keys on
+	// both curves have the same discrete logarithm. The proof for the share
+	// with index i is ShareProofs[i-1].
+	ShareProofs []*dleq.FullRangeProof
+}
+
+// Share is a single share of a witness.
+type Share struct {
+	// Index is the point at which the sharing polynomial is evaluated, from 1
+	// to the number of shares.
+	Index uint8
+	// Value is the share's secret in little-endian, which is smaller than the
+	// order of curve B.
+	Value []byte
+}
+
+// curveOrder returns the order of curve B, which the polynomial is over.
+func curveOrder(curveA, curveB types.Curve) (*big.Int, error) {
+	orderer, ok := curveB.(types.Orderer)
+	if !ok {
+		return nil, errors.New("curve B must implement types.Orderer")
+	}
+
+	order := orderer.Order()
+	if curveA.BitSize() <= uint64(order.BitLen()) {
+		return nil, fmt.Errorf("curve A must have a bit size greater than %d", order.BitLen())
+	}
+
+	return order, nil
+}
+
+// Deal splits the witness x into n shares, any threshold of which reconstruct
+// it. The witness must be in little-endian and smaller than the minimum order
+// of the two curves, as for dleq.NewProof.
+//
+// Curve B must implement types.Orderer, and curve A's BitSize must be greater
+// than the bit length of curve B's order, as for dleq.NewFullRangeProof; eg.
+// curve A is secp256k1 and curve B is ed25519.
+// Only WithRadix and WithCompactEncoding can be set, and they apply to all of
+// the proofs.
+func Deal(curveA, curveB types.Curve, x []byte, threshold, n int, opts ...dleq.Option) (*Dealing, []*Share, error) {
+	order, err := curveOrder(curveA, curveB)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if n < 1 || n > MaxShares {
+		return nil, nil, fmt.Errorf("number of shares must be between 1 and %d, got %d", MaxShares, n)
+	}
+
+	if threshold < 1 || threshold > n {
+		return nil, nil, fmt.Errorf("threshold must be between 1 and %d, got %d", n, threshold)
+	}
+
+	// the share proofs only accept WithRadix and WithCompactEncoding, so the
+	// options are checked before any proof is generated
+	err = fullrange.CheckOptions(curveA, curveB, opts)
+	if errors.Is(err, fullrange.ErrOptions) {
+		return nil, nil, errDealOptions
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof, err := dleq.NewProof(curveA, curveB, x, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// f(0) = x, and the other coefficients are random
+	coefficients := make([]*big.Int, threshold)
+	coefficients[0] = littleendian.Int(x)
+	for k := 1; k < threshold; k++ {
+		coefficients[k], err = rand.Int(rand.Reader, order)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	commitments := make([]types.Point, threshold)
+	commitments[0] = proof.CommitmentB
+	for k := 1; k < threshold; k++ {
+		commitments[k] = curveB.ScalarBaseMul(scalarFromInt(curveB, coefficients[k]))
+	}
+
+	shares := make([]*Share, n)
+	shareProofs := make([]*dleq.FullRangeProof, n)
+	for i := range shares {
+		index := uint8(i + 1)
+		value := littleendian.Bytes(evaluate(coefficients, index, order), curveB.ScalarSize())
+		shareProofs[i], err = dleq.NewFullRangeProof(curveA, curveB, value, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		shares[i] = &Share{
+			Index: index,
+			Value: value,
+		}
+	}
+
+	return &Dealing{
+		Proof:       proof,
+		Commitments: commitments,
+		ShareProofs: shareProofs,
+	}, shares, nil
+}
+
+// evaluate returns f(i) modulo the order, where f has the given coefficients.
+func evaluate(coefficients []*big.Int, i uint8, order *big.Int) *big.Int {
+	// Horner's method
+	x := big.NewInt(int64(i))
+	y := new(big.Int)
+	for k := len(coefficients) - 1; k >= 0; k-- {
+		y.Mul(y, x)
+		y.Add(y, coefficients[k])
+		y.Mod(y, order)
+	}
+
+	return y
+}
+
+// Threshold returns the number of shares required to reconstruct the witness.
+func (d *Dealing) Threshold() int {
+	return len(d.Commitments)
+}
+
+// NumShares returns the number of shares of the dealing.
+func (d *Dealing) NumShares() int {
+	return len(d.ShareProofs)
+}
+
+// Verify verifies the dealing's proofs, and that the public keys of every
+// share are consistent with the commitments to the sharing polynomial.
+// By default, the witness proof must be for a witness of the full bit length;
+// see dleq.WithMinBits.
+func (d *Dealing) Verify(curveA, curveB types.Curve, opts ...dleq.VerifyOption) error {
+	_, err := curveOrder(curveA, curveB)
+	if err != nil {
+		return err
+	}
+
+	if d.Proof == nil || len(d.Commitments) == 0 || len(d.ShareProofs) > MaxShares ||
+		len(d.Commitments) > len(d.ShareProofs) {
+		return errInvalidDealing
+	}
+
+	err = d.Proof.Verify(curveA, curveB, opts...)
+	if err != nil {
+		return err
+	}
+
+	if d.Commitments[0] == nil || !d.Commitments[0].Equals(d.Proof.CommitmentB) {
+		return errors.New("first coefficient commitment must be the witness commitment")
+	}
+
+	for i, proof := range d.ShareProofs {
+		if proof == nil {
+			return errInvalidDealing
+		}
+
+		err = proof.Verify(curveA, curveB)
+		if err != nil {
+			return fmt.Errorf("failed to verify proof of share %d: %w", i+1, err)
+		}
+
+		if !proof.CommitmentB.Equals(d.evaluateCommitments(curveB, uint8(i+1))) {
+			return fmt.Errorf("share %d is inconsistent with the commitments", i+1)
+		}
+	}
+
+	return nil
+}
+
+// evaluateCommitments returns f(i)*G_B, computed from the coefficient commitments.
+func (d *Dealing) evaluateCommitments(curveB types.Curve, i uint8) types.Point {
+	x := curveB.ScalarFromInt(uint32(i))
+	y := d.Commitments[len(d.Commitments)-1].Copy()
+	for k := len(d.Commitments) - 2; k >= 0; k-- {
+		y = y.ScalarMul(x).Add(d.Commitments[k])
+	}
+
+	return y
+}
+
+// PublicKeys returns the public keys of the share with the given index on
+// both curves.
+func (d *Dealing) PublicKeys(index uint8) (types.Point, types.Point, error) {
+	if index == 0 || int(index) > len(d.ShareProofs) {
+		return nil, nil, fmt.Errorf("share index must be between 1 and %d, got %d", len(d.ShareProofs), index)
+	}
+
+	proof := d.ShareProofs[index-1]
+	return proof.CommitmentA, proof.CommitmentB, nil
+}
+
+// VerifyShare verifies that the share's secret is the discrete logarithm of
+// its public keys. The dealing must have already been verified.
+func (d *Dealing) VerifyShare(curveA, curveB types.Curve, share *Share) error {
+	order, err := curveOrder(curveA, curveB)
+	if err != nil {
+		return err
+	}
+
+	XA, XB, err := d.PublicKeys(share.Index)
+	if err != nil {
+		return err
+	}
+
+	if len(share.Value) != curveB.ScalarSize() || littleendian.Int(share.Value).Cmp(order) >= 0 {
+		return errors.New("share must be smaller than the order of curve B")
+	}
+
+	// the share is smaller than both orders, so it's the same scalar on both curves
+	value := littleendian.Int(share.Value)
+	if !curveA.ScalarBaseMul(scalarFromInt(curveA, value)).Equals(XA) ||
+		!curveB.ScalarBaseMul(scalarFromInt(curveB, value)).Equals(XB) {
+		return fmt.Errorf("share %d does not match its public keys", share.Index)
+	}
+
+	return nil
+}
+
+// Reconstruct verifies the given shares and reconstructs the witness from
+// them, returning it in little-endian. At least a threshold of distinct shares
+// is required. The dealing must have already been verified.
+func (d *Dealing) Reconstruct(curveA, curveB types.Curve, shares []*Share) ([]byte, error) {
+	order, err := curveOrder(curveA, curveB)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := d.Threshold()
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("at least %d shares are required, got %d", threshold, len(shares))
+	}
+
+	seen := make(map[uint8]struct{}, threshold)
+	for _, share := range shares[:threshold] {
+		if _, has := seen[share.Index]; has {
+			return nil, errors.New("shares must be distinct")
+		}
+		seen[share.Index] = struct{}{}
+
+		err = d.VerifyShare(curveA, curveB, share)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// x = f(0) = sum(f(i) * l_i(0)), where l_i(0) = prod(j / (j - i)) for j != i
+	x := new(big.Int)
+	for _, share := range shares[:threshold] {
+		num, den := big.NewInt(1), big.NewInt(1)
+		for _, other := range shares[:threshold] {
+			if other.Index == share.Index {
+				continue
+			}
+
+			num.Mul(num, big.NewInt(int64(other.Index)))
+			den.Mul(den, big.NewInt(int64(other.Index)-int64(share.Index)))
+		}
+
+		den.Mod(den, order)
+		coefficient := num.Mul(num, den.ModInverse(den, order))
+		x.Add(x, coefficient.Mul(coefficient, littleendian.Int(share.Value)))
+		x.Mod(x, order)
+	}
+
+	witness := littleendian.Bytes(x, curveB.ScalarSize())
+	if !curveB.ScalarBaseMul(scalarFromInt(curveB, x)).Equals(d.Proof.CommitmentB) {
+		return nil, errors.New("reconstructed witness does not match the commitment")
+	}
+
+	return witness, nil
+}
+
+// Serialize encodes the dealing.
+//
+// The encoding is: number of coefficients (1 byte) || number of shares
+// (1 byte) || coefficient commitments, except the first ||
+// proof length (4 bytes, little-endian) || proof ||
+// for each share: proof length (4 bytes, little-endian) || share proof.
+func (d *Dealing) Serialize() []byte {
+	b := []byte{byte(len(d.Commitments)), byte(len(d.ShareProofs))}
+	for _, c := range d.Commitments[1:] {
+		b = append(b, c.Encode()...)
+	}
+
+	b = appendWithLength(b, d.Proof.Serialize())
+	for _, proof := range d.ShareProofs {
+		b = appendWithLength(b, proof.Serialize())
+	}
+
+	return b
+}
+
+func appendWithLength(b, in []byte) []byte {
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(in)))
+	b = append(b, length[:]...)
+	return append(b, in...)
+}
+
+// Deserialize decodes the dealing for the given curves.
+func (d *Dealing) Deserialize(curveA, curveB types.Curve, in []byte) error {
+	if len(in) < 2 {
+		return errInputBytesTooShort
+	}
+
+	threshold, n := int(in[0]), int(in[1])
+	if threshold == 0 || threshold > n {
+		return errInvalidDealing
+	}
+	in = in[2:]
+
+	pointLen := curveB.CompressedPointSize()
+	if len(in) < (threshold-1)*pointLen {
+		return errInputBytesTooShort
+	}
+
+	commitments := make([]types.Point, threshold)
+	for k := 1; k < threshold; k++ {
+		var err error
+		commitments[k], err = curveB.DecodeToPoint(in[:pointLen])
+		if err != nil {
+			return err
+		}
+		in = in[pointLen:]
+	}
+
+	b, in, err := readWithLength(in)
+	if err != nil {
+		return err
+	}
+
+	proof := new(dleq.Proof)
+	err = proof.Deserialize(curveA, curveB, b)
+	if err != nil {
+		return err
+	}
+	commitments[0] = proof.CommitmentB
+
+	shareProofs := make([]*dleq.FullRangeProof, n)
+	for i := range shareProofs {
+		b, in, err = readWithLength(in)
+		if err != nil {
+			return err
+		}
+
+		shareProofs[i] = new(dleq.FullRangeProof)
+		err = shareProofs[i].Deserialize(curveA, curveB, b)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.Proof = proof
+	d.Commitments = commitments
+	d.ShareProofs = shareProofs
+	return nil
+}
+
+// readWithLength reads a length-prefixed value, returning it and the rest of
+// the input.
+func readWithLength(in []byte) ([]byte, []byte, error) {
+	if len(in) < 4 {
+		return nil, nil, errInputBytesTooShort
+	}
+
+	n := binary.LittleEndian.Uint32(in)
+	in = in[4:]
+	if uint64(len(in)) < uint64(n) {
+		return nil, nil, errInputBytesTooShort
+	}
+
+	return in[:n], in[n:], nil
+}
+
+// Serialize encodes the share as index (1 byte) || value.
+func (s *Share) Serialize() []byte {
+	return append([]byte{s.Index}, s.Value...)
+}
+
+// Deserialize decodes the share for the given curve B.
+func (s *Share) Deserialize(curveB types.Curve, in []byte) error {
+	if len(in) != 1+curveB.ScalarSize() {
+		return errors.New("invalid share length")
+	}
+
+	s.Index = in[0]
+	s.Value = append([]byte{}, in[1:]...)
+	return nil
+}
+
+// scalarFromInt returns n as a scalar on the curve. n must be non-negative.
+func scalarFromInt(curve types.Curve, n *big.Int) types.Scalar {
+	return curve.ScalarFromBytes(littleendian.Bytes(n, curve.ScalarSize()))
+}
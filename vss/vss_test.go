@@ -0,0 +1,163 @@
+package vss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dleq "github.com/athanorlabs/go-dleq"
+	"github.com/athanorlabs/go-dleq/ed25519"
+	"github.com/athanorlabs/go-dleq/secp256k1"
+	"github.com/athanorlabs/go-dleq/types"
+)
+
+func deal(t *testing.T, threshold, n int) (types.Curve, types.Curve, []byte, *Dealing, []*Share) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := dleq.GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+
+	dealing, shares, err := Deal(curveA, curveB, x, threshold, n, dleq.WithRadix(16))
+	require.NoError(t, err)
+	require.Equal(t, threshold, dealing.Threshold())
+	require.Equal(t, n, dealing.NumShares())
+	err = dealing.Verify(curveA, curveB)
+	require.NoError(t, err)
+	return curveA, curveB, x, dealing, shares
+}
+
+func TestDealAndReconstruct(t *testing.T) {
+	curveA, curveB, x, dealing, shares := deal(t, 2, 3)
+
+	for _, share := range shares {
+		err := dealing.VerifyShare(curveA, curveB, share)
+		require.NoError(t, err)
+	}
+
+	// any two shares reconstruct the witness
+	for _, subset := range [][]*Share{
+		{shares[0], shares[1]},
+		{shares[2], shares[0]},
+		{shares[1], shares[2]},
+	} {
+		witness, err := dealing.Reconstruct(curveA, curveB, subset)
+		require.NoError(t, err)
+		require.Equal(t, x, witness)
+	}
+
+	// a single share is not enough
+	_, err := dealing.Reconstruct(curveA, curveB, shares[:1])
+	require.Error(t, err)
+
+	// duplicate shares are rejected
+	_, err = dealing.Reconstruct(curveA, curveB, []*Share{shares[0], shares[0]})
+	require.Error(t, err)
+
+	// a share with the wrong index doesn't match its public keys
+	bad := &Share{
+		Index: shares[1].Index,
+		Value: shares[0].Value,
+	}
+	err = dealing.VerifyShare(curveA, curveB, bad)
+	require.Error(t, err)
+	_, err = dealing.Reconstruct(curveA, curveB, []*Share{shares[0], bad})
+	require.Error(t, err)
+
+	bad = &Share{
+		Index: 4,
+		Value: shares[0].Value,
+	}
+	err = dealing.VerifyShare(curveA, curveB, bad)
+	require.Error(t, err)
+}
+
+func TestDeal_PublicKeys(t *testing.T) {
+	curveA, curveB, _, dealing, shares := deal(t, 1, 2)
+
+	// with a threshold of 1, each share is the witness itself
+	for _, share := range shares {
+		XA, XB, err := dealing.PublicKeys(share.Index)
+		require.NoError(t, err)
+		require.True(t, XA.Equals(dealing.Proof.CommitmentA))
+		require.True(t, XB.Equals(dealing.Proof.CommitmentB))
+
+		witness, err := dealing.Reconstruct(curveA, curveB, []*Share{share})
+		require.NoError(t, err)
+		require.Equal(t, share.Value, witness)
+	}
+
+	_, _, err := dealing.PublicKeys(0)
+	require.Error(t, err)
+	_, _, err = dealing.PublicKeys(3)
+	require.Error(t, err)
+}
+
+func TestDeal_Options(t *testing.T) {
+	curveA := secp256k1.NewCurve()
+	curveB := ed25519.NewCurve()
+	x, err := dleq.GenerateSecretForCurves(curveA, curveB)
+	require.NoError(t, err)
+
+	// the share proofs are full-range, so the bit length can't be set
+	_, _, err = Deal(curveA, curveB, x, 2, 3, dleq.WithBits(252))
+	require.ErrorIs(t, err, errDealOptions)
+	_, _, err = Deal(curveA, curveB, x, 2, 3, dleq.WithSignatureScheme(types.BIP340))
+	require.ErrorIs(t, err, errDealOptions)
+
+	dealing, _, err := Deal(curveA, curveB, x, 2, 3, dleq.WithRadix(16), dleq.WithCompactEncoding())
+	require.NoError(t, err)
+	err = dealing.Verify(curveA, curveB)
+	require.NoError(t, err)
+}
+
+func TestDealing_InvalidCommitments(t *testing.T) {
+	curveA, curveB, _, dealing, _ := deal(t, 2, 2)
+
+	// changing a coefficient commitment makes the shares inconsistent
+	commitment := dealing.Commitments[1]
+	dealing.Commitments[1] = commitment.Add(curveB.BasePoint())
+	err := dealing.Verify(curveA, curveB)
+	require.Error(t, err)
+	dealing.Commitments[1] = commitment
+
+	// swapping share proofs makes the shares inconsistent
+	dealing.ShareProofs[0], dealing.ShareProofs[1] = dealing.ShareProofs[1], dealing.ShareProofs[0]
+	err = dealing.Verify(curveA, curveB)
+	require.Error(t, err)
+
+	_, _, err = Deal(curveA, curveB, []byte{1}, 3, 2)
+	require.Error(t, err)
+	_, _, err = Deal(curveA, curveB, []byte{1}, 0, 2)
+	require.Error(t, err)
+
+	// curve A must be larger than curve B's order
+	_, _, err = Deal(curveB, curveB, []byte{1}, 1, 2)
+	require.Error(t, err)
+}
+
+func TestSerialize(t *testing.T) {
+	curveA, curveB, x, dealing, shares := deal(t, 2, 2)
+
+	ser := dealing.Serialize()
+	deser := new(Dealing)
+	err := deser.Deserialize(curveA, curveB, ser)
+	require.NoError(t, err)
+	require.Equal(t, ser, deser.Serialize())
+	err = deser.Verify(curveA, curveB)
+	require.NoError(t, err)
+
+	err = deser.Deserialize(curveA, curveB, ser[:len(ser)-1])
+	require.Error(t, err)
+
+	deserShares := make([]*Share, len(shares))
+	for i, share := range shares {
+		deserShares[i] = new(Share)
+		err = deserShares[i].Deserialize(curveB, share.Serialize())
+		require.NoError(t, err)
+		require.Equal(t, share, deserShares[i])
+	}
+
+	witness, err := deser.Reconstruct(curveA, curveB, deserShares)
+	require.NoError(t, err)
+	require.Equal(t, x, witness)
+}